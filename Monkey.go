@@ -1,14 +1,83 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 
+	"github.com/lukeomalley/monkey_lang/checker"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/dap"
+	"github.com/lukeomalley/monkey_lang/debugger"
+	"github.com/lukeomalley/monkey_lang/format"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/lint"
+	"github.com/lukeomalley/monkey_lang/lsp"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/parser"
 	"github.com/lukeomalley/monkey_lang/repl"
+	"github.com/lukeomalley/monkey_lang/vm"
 )
 
+const usage = `Usage: monkey [command] [arguments]
+
+Commands:
+	repl                      start the interactive repl (default)
+	run [-io] <file>          compile and run a script
+	debug [-io] <file>        run a script under the interactive debugger
+	disasm <file>             print the bytecode of a script
+	fmt [-w] [-d] [files]     format scripts, or standard input without files
+	lint [-json] <files>      report likely mistakes in scripts
+	check <files>             report type errors in scripts
+	dap                       serve the Debug Adapter Protocol on stdin and stdout
+	lsp                       serve the Language Server Protocol on stdin and stdout
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		startRepl()
+		return
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "repl":
+		startRepl()
+	case "run":
+		err = runCommand(args)
+	case "debug":
+		err = debugCommand(args)
+	case "disasm":
+		err = disasmCommand(args)
+	case "fmt":
+		err = fmtCommand(args)
+	case "lint":
+		err = lintCommand(args)
+	case "check":
+		err = checkCommand(args)
+	case "dap":
+		err = dap.Serve(os.Stdin, os.Stdout)
+	case "lsp":
+		err = lsp.Serve(os.Stdin, os.Stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func startRepl() {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -17,3 +86,301 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	allowIO := flags.Bool("io", false, "allow file access below the script directory and reading stdin")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey run [-io] <file>")
+	}
+
+	builtins := object.NewRegistry()
+	if *allowIO {
+		sandbox := object.NewDirSandbox(filepath.Dir(flags.Arg(0)))
+		sandbox.Stdin = os.Stdin
+		builtins.SetSandbox(sandbox)
+	}
+
+	bytecode, err := compileFile(flags.Arg(0), builtins)
+	if err != nil {
+		return err
+	}
+
+	machine := vm.NewWithBuiltins(bytecode, builtins)
+	return machine.Run()
+}
+
+func debugCommand(args []string) error {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	allowIO := flags.Bool("io", false, "allow file access below the script directory")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey debug [-io] <file>")
+	}
+
+	// Stdin is left to the debugger, which reads its commands from it
+	builtins := object.NewRegistry()
+	if *allowIO {
+		builtins.SetSandbox(object.NewDirSandbox(filepath.Dir(flags.Arg(0))))
+	}
+
+	bytecode, err := compileFile(flags.Arg(0), builtins)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	machine := vm.NewWithBuiltins(bytecode, builtins)
+	return debugger.Start(machine, string(src), os.Stdin, os.Stdout)
+}
+
+func disasmCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: monkey disasm <file>")
+	}
+
+	bytecode, err := compileFile(args[0], object.NewRegistry())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(os.Stdout, compiler.Disassemble(bytecode))
+	return err
+}
+
+func fmtCommand(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the formatted source back to the files")
+	diff := flags.Bool("d", false, "print a diff of the changes and fail when a file is not formatted")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			return fmt.Errorf("cannot use -w with standard input")
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			return fmt.Errorf("<stdin>:%s", err)
+		}
+
+		if *diff {
+			fmt.Print(format.Diff("<stdin>", string(src), formatted))
+			if formatted != string(src) {
+				return fmt.Errorf("<stdin> is not formatted")
+			}
+			return nil
+		}
+
+		fmt.Print(formatted)
+		return nil
+	}
+
+	failed, unformatted := 0, 0
+	for _, path := range flags.Args() {
+		changed, err := fmtFile(path, *write, *diff)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+		}
+		if changed {
+			unformatted++
+		}
+	}
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("could not format %d of %d files", failed, flags.NArg())
+	case *diff && unformatted > 0:
+		return fmt.Errorf("%d of %d files are not formatted", unformatted, flags.NArg())
+	}
+
+	return nil
+}
+
+// fmtFile formats a script, reporting whether its formatting changed
+func fmtFile(path string, write, diff bool) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	formatted, err := format.Source(string(src))
+	if err != nil {
+		return false, fmt.Errorf("%s:%s", path, err)
+	}
+
+	changed := formatted != string(src)
+	if diff {
+		fmt.Print(format.Diff(path, string(src), formatted))
+	}
+
+	if write && changed {
+		if err := os.WriteFile(path, []byte(formatted), info.Mode().Perm()); err != nil {
+			return changed, err
+		}
+	}
+
+	if !write && !diff {
+		fmt.Print(formatted)
+	}
+
+	return changed, nil
+}
+
+// lintReport is a problem found by the linter, as printed by lint -json
+type lintReport struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func lintCommand(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the problems as a JSON array")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: monkey lint [-json] <files>")
+	}
+
+	builtins := object.NewRegistry()
+	reports := []lintReport{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, problem := range lint.Source(string(src), builtins) {
+			reports = append(reports, lintReport{
+				File:    path,
+				Line:    problem.Pos.Line,
+				Column:  problem.Pos.Column,
+				Rule:    problem.Rule,
+				Message: problem.Message,
+			})
+		}
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, r := range reports {
+			fmt.Printf("%s:%d:%d: %s (%s)\n", r.File, r.Line, r.Column, r.Message, r.Rule)
+		}
+	}
+
+	if len(reports) > 0 {
+		return fmt.Errorf("found %d problems", len(reports))
+	}
+
+	return nil
+}
+
+func checkCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: monkey check <files>")
+	}
+
+	builtins := object.NewRegistry()
+	failed := 0
+	for _, path := range args {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := checkSource(path, string(src), builtins); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files have errors", failed, len(args))
+	}
+
+	return nil
+}
+
+// checkSource parses and type checks a script
+func checkSource(path, src string, builtins *object.Registry) error {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.SyntaxErrors(); len(errs) != 0 {
+		list := make(compiler.ErrorList, len(errs))
+		for i, e := range errs {
+			list[i] = compiler.Error{Pos: e.Pos, Message: e.Message}
+		}
+		return fileErrors(path, list)
+	}
+
+	if err := checker.Check(program, builtins); err != nil {
+		return fileErrors(path, err)
+	}
+	return nil
+}
+
+// fileErrors prefixes every positioned error with the path of the script
+func fileErrors(path string, err error) error {
+	var msgs []string
+	switch err := err.(type) {
+	case compiler.ErrorList:
+		for _, e := range err {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", path, e))
+		}
+	default:
+		msgs = append(msgs, fmt.Sprintf("%s:%s", path, err))
+	}
+
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// compileFile parses and compiles a script, resolving builtins from the registry
+func compileFile(path string, builtins *object.Registry) (*compiler.Bytecode, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msg := fmt.Sprintf("%s: parser errors:", path)
+		for _, e := range p.Errors() {
+			msg += "\n\t" + e
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	comp := compiler.NewWithBuiltins(builtins)
+	if err := comp.Compile(program); err != nil {
+		return nil, fileErrors(path, err)
+	}
+
+	return comp.Bytecode(), nil
+}
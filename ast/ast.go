@@ -20,6 +20,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -33,6 +41,7 @@ func (p *Program) String() string {
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the node's token
 }
 
 type Statement interface {
@@ -56,6 +65,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -82,11 +92,15 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Name.Type != nil {
+		out.WriteString(": " + ls.Name.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -110,6 +124,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -118,6 +133,72 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// ============================================================================
+// Struct Statements
+// ============================================================================
+
+// StructStatement declares a struct type, e.g. struct Point { x, y }. Fields
+// may be annotated like parameters.
+type StructStatement struct {
+	Token  token.Token // the struct token
+	Name   *Identifier
+	Brace  token.Token // the '{' token opening the fields
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) Pos() token.Position  { return ss.Token.Pos }
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range ss.Fields {
+		field := f.String()
+		if f.Type != nil {
+			field += ": " + f.Type.String()
+		}
+		fields = append(fields, field)
+	}
+
+	out.WriteString(ss.TokenLiteral() + " ")
+	out.WriteString(ss.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// ============================================================================
+// Assign Statements
+// ============================================================================
+
+// AssignStatement assigns a value to the field of a struct, e.g. p.x = 3
+type AssignStatement struct {
+	Token  token.Token // the first token of the target
+	Target *SelectorExpression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position  { return as.Token.Pos }
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" = ")
+
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
 // ============================================================================
 // Identifiers
 // ============================================================================
@@ -125,10 +206,12 @@ func (es *ExpressionStatement) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+	Type  TypeExpression // annotation of a let name or parameter, nil when omitted
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -144,8 +227,23 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// ============================================================================
+// Float Literal
+// ============================================================================
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // ============================================================================
 // Prefix Expression
 // ============================================================================
@@ -158,6 +256,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -182,6 +281,7 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -203,6 +303,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 // ============================================================================
@@ -218,6 +319,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -245,6 +347,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -262,17 +365,23 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	ReturnType TypeExpression // nil when omitted
 	Body       *BlockStatement
 	Name       string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
 
 	for _, p := range fl.Parameters {
+		if p.Type != nil {
+			params = append(params, p.String()+": "+p.Type.String())
+			continue
+		}
 		params = append(params, p.String())
 	}
 
@@ -283,6 +392,9 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -300,6 +412,7 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -327,6 +440,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 // ============================================================================
@@ -340,6 +454,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -367,6 +482,7 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -379,6 +495,74 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// ============================================================================
+// Slice Expression
+// ============================================================================
+
+type SliceExpression struct {
+	Token token.Token // the '[' token
+	Left  Expression
+	Start Expression // nil when omitted
+	End   Expression // nil when omitted
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// ============================================================================
+// Selector Expression
+// ============================================================================
+
+type SelectorExpression struct {
+	Token token.Token // the '.' token
+	Left  Expression
+	Field *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SelectorExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString(".")
+	out.WriteString(se.Field.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// QualifiedName returns the dotted name of selectors such as math.abs, which may
+// refer to a builtin registered within a namespace
+func (se *SelectorExpression) QualifiedName() (string, bool) {
+	ident, ok := se.Left.(*Identifier)
+	if !ok {
+		return "", false
+	}
+
+	return ident.Value + "." + se.Field.Value, true
+}
+
 // ============================================================================
 // Hash Literal
 // ============================================================================
@@ -386,22 +570,94 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("{")
+	out.WriteString("}")
 
 	return out.String()
 }
+
+// ============================================================================
+// Type Annotations
+// ============================================================================
+
+// TypeExpression is a type written in an annotation
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type referred to by name, e.g. int or any
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType is the type of arrays of a single element type, e.g. [int]
+type ArrayType struct {
+	Token   token.Token
+	Element TypeExpression
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) Pos() token.Position  { return at.Token.Pos }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+// HashType is the type of hashes of a key and a value type, e.g. {string: int}
+type HashType struct {
+	Token token.Token
+	Key   TypeExpression
+	Value TypeExpression
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) Pos() token.Position  { return ht.Token.Pos }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType is the type of functions, e.g. fn(int, int): int
+type FunctionType struct {
+	Token      token.Token
+	Parameters []TypeExpression
+	Return     TypeExpression // nil when omitted
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Return != nil {
+		out += ": " + ft.Return.String()
+	}
+
+	return out
+}
@@ -0,0 +1,150 @@
+package checker
+
+import "strings"
+
+// expect is the type accepted for an argument of a builtin
+type expect struct {
+	kinds []Kind // accepted kinds, any kind when empty
+}
+
+func oneOf(kinds ...Kind) expect {
+	return expect{kinds: kinds}
+}
+
+var anything = expect{}
+
+func (e expect) accepts(t *Type) bool {
+	if t.Kind == Any || len(e.kinds) == 0 {
+		return true
+	}
+
+	for _, k := range e.kinds {
+		if t.Kind == k || k == Float && t.Kind == Int {
+			return true
+		}
+	}
+	return false
+}
+
+func (e expect) String() string {
+	names := make([]string, len(e.kinds))
+	for i, k := range e.kinds {
+		names[i] = (&Type{Kind: k}).kindName()
+	}
+
+	switch len(names) {
+	case 0:
+		return "any"
+	case 1:
+		return names[0]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+}
+
+// kindName names the kind of a type, without its parameters
+func (t *Type) kindName() string {
+	switch t.Kind {
+	case Array:
+		return "array"
+	case Hash:
+		return "hash"
+	case Function:
+		return "function"
+	default:
+		return t.String()
+	}
+}
+
+// signature is the static type of a builtin. Arguments beyond params are not
+// checked.
+type signature struct {
+	params []expect
+	result func(args []*Type) *Type
+}
+
+func returns(t *Type) func([]*Type) *Type {
+	return func([]*Type) *Type { return t }
+}
+
+// sameAs returns the type of the argument at index i
+func sameAs(i int) func([]*Type) *Type {
+	return func(args []*Type) *Type { return args[i] }
+}
+
+// elementOf returns the element type of the array or hash at index i
+func elementOf(i int) func([]*Type) *Type {
+	return func(args []*Type) *Type {
+		if args[i].Elem == nil {
+			return AnyType
+		}
+		return args[i].Elem
+	}
+}
+
+// builtinSignatures are the types of the builtins the checker knows about,
+// builtins missing from it accept anything and return any
+var builtinSignatures = map[string]signature{
+	"len":   {[]expect{oneOf(String, Array, Hash)}, returns(IntType)},
+	"puts":  {nil, returns(NullType)},
+	"first": {[]expect{oneOf(Array)}, elementOf(0)},
+	"last":  {[]expect{oneOf(Array)}, elementOf(0)},
+	"rest":  {[]expect{oneOf(Array)}, sameAs(0)},
+	"push": {[]expect{oneOf(Array), anything}, func(args []*Type) *Type {
+		if args[0].Kind == Array && args[1].AssignableTo(args[0].Elem) {
+			return args[0]
+		}
+		return ArrayOf(AnyType)
+	}},
+	"print":  {nil, returns(NullType)},
+	"eprint": {nil, returns(NullType)},
+
+	"map":       {[]expect{oneOf(Array), oneOf(Function)}, returns(ArrayOf(AnyType))},
+	"filter":    {[]expect{oneOf(Array), oneOf(Function)}, sameAs(0)},
+	"reduce":    {[]expect{oneOf(Array), oneOf(Function)}, returns(AnyType)},
+	"sort":      {[]expect{oneOf(Array)}, sameAs(0)},
+	"sort_by":   {[]expect{oneOf(Array), oneOf(Function)}, sameAs(0)},
+	"reverse":   {[]expect{oneOf(Array, String)}, sameAs(0)},
+	"range":     {[]expect{oneOf(Int), oneOf(Int), oneOf(Int)}, returns(ArrayOf(IntType))},
+	"zip":       {[]expect{oneOf(Array), oneOf(Array)}, returns(ArrayOf(ArrayOf(AnyType)))},
+	"enumerate": {[]expect{oneOf(Array)}, returns(ArrayOf(ArrayOf(AnyType)))},
+	"contains":  {[]expect{oneOf(String, Array, Hash), anything}, returns(BoolType)},
+	"keys": {[]expect{oneOf(Hash)}, func(args []*Type) *Type {
+		if args[0].Key == nil {
+			return ArrayOf(AnyType)
+		}
+		return ArrayOf(args[0].Key)
+	}},
+	"values": {[]expect{oneOf(Hash)}, func(args []*Type) *Type {
+		return ArrayOf(elementOf(0)(args))
+	}},
+	"has_key": {[]expect{oneOf(Hash), anything}, returns(BoolType)},
+	"delete":  {[]expect{oneOf(Hash), anything}, sameAs(0)},
+	"flatten": {[]expect{oneOf(Array)}, returns(ArrayOf(AnyType))},
+	"unique":  {[]expect{oneOf(Array)}, sameAs(0)},
+
+	"split":       {[]expect{oneOf(String), oneOf(String)}, returns(ArrayOf(StringType))},
+	"join":        {[]expect{oneOf(Array), oneOf(String)}, returns(StringType)},
+	"trim":        {[]expect{oneOf(String), oneOf(String)}, returns(StringType)},
+	"upper":       {[]expect{oneOf(String)}, returns(StringType)},
+	"lower":       {[]expect{oneOf(String)}, returns(StringType)},
+	"replace":     {[]expect{oneOf(String), oneOf(String), oneOf(String)}, returns(StringType)},
+	"starts_with": {[]expect{oneOf(String), oneOf(String)}, returns(BoolType)},
+	"ends_with":   {[]expect{oneOf(String), oneOf(String)}, returns(BoolType)},
+	"index_of":    {[]expect{oneOf(String), oneOf(String)}, returns(IntType)},
+	"repeat":      {[]expect{oneOf(String), oneOf(Int)}, returns(StringType)},
+	"chars":       {[]expect{oneOf(String)}, returns(ArrayOf(StringType))},
+	"format":      {[]expect{oneOf(String)}, returns(StringType)},
+
+	"type":    {nil, returns(StringType)},
+	"str":     {nil, returns(StringType)},
+	"inspect": {nil, returns(StringType)},
+
+	"math.abs":    {[]expect{oneOf(Float)}, sameAs(0)},
+	"math.sqrt":   {[]expect{oneOf(Float)}, returns(FloatType)},
+	"math.floor":  {[]expect{oneOf(Float)}, returns(IntType)},
+	"math.ceil":   {[]expect{oneOf(Float)}, returns(IntType)},
+	"math.round":  {[]expect{oneOf(Float)}, returns(IntType)},
+	"math.pow":    {[]expect{oneOf(Float), oneOf(Float)}, returns(AnyType)},
+	"math.random": {nil, returns(FloatType)},
+}
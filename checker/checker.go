@@ -0,0 +1,606 @@
+package checker
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/resolve"
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// Check infers the types of a program and reports the operations that would
+// fail at runtime because of them, as a compiler.ErrorList. Annotations are checked
+// where they are given; values of unknown type are accepted everywhere.
+func Check(program *ast.Program, builtins *object.Registry) error {
+	c := &checker{
+		names:    resolve.New(builtins),
+		builtins: make(map[string]*object.Builtin),
+	}
+
+	for _, b := range builtins.Builtins() {
+		c.builtins[b.Name] = b
+	}
+
+	c.statements(program.Statements)
+
+	if len(c.errors) == 0 {
+		return nil
+	}
+
+	c.errors.Sort()
+	return c.errors
+}
+
+// binding is a name bound by let, struct or a function parameter
+type binding struct {
+	typ       *Type
+	declared  bool  // the type comes from an annotation
+	structure *Type // the struct type declared, when bound by struct
+}
+
+// function is a function literal being checked
+type function struct {
+	result  *Type   // annotated result type, nil when inferred
+	returns []*Type // types of the values returned so far
+}
+
+// checker resolves names with symbol tables exactly as the compiler does
+type checker struct {
+	errors compiler.ErrorList
+
+	names     *resolve.Resolver
+	builtins  map[string]*object.Builtin
+	enclosing []*function
+}
+
+// statements checks a block and returns the type of its value
+func (c *checker) statements(stmts []ast.Statement) *Type {
+	result := NullType
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func (c *checker) statement(stmt ast.Statement) *Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		b := c.define(stmt.Name.Value)
+		if stmt.Name.Type != nil {
+			b.typ, b.declared = c.annotation(stmt.Name.Type), true
+		}
+
+		var value *Type
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			value = c.function(fn, b)
+		} else {
+			value = c.expression(stmt.Value)
+		}
+
+		if !b.declared {
+			b.typ = value
+		} else if !value.AssignableTo(b.typ) {
+			c.errorf(stmt.Value.Pos(), "cannot use %s as %s in let %s", value, b.typ, stmt.Name.Value)
+		}
+		return NullType
+
+	case *ast.StructStatement:
+		c.structure(stmt)
+		return NullType
+
+	case *ast.AssignStatement:
+		c.assign(stmt)
+		return NullType
+
+	case *ast.ReturnStatement:
+		value := c.expression(stmt.ReturnValue)
+		c.returned(value, stmt.Pos())
+		return value
+
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+	}
+
+	return AnyType
+}
+
+// structure declares a struct type. Its name is bound to the constructor, a
+// function taking the fields in order.
+func (c *checker) structure(stmt *ast.StructStatement) {
+	b := c.define(stmt.Name.Value)
+	b.structure = StructOf(stmt.Name.Value, nil)
+
+	// Fields are annotated after the name is bound, so they may refer to it
+	params := make([]*Type, len(stmt.Fields))
+	for i, f := range stmt.Fields {
+		params[i] = AnyType
+		if f.Type != nil {
+			params[i] = c.annotation(f.Type)
+		}
+		b.structure.Fields = append(b.structure.Fields, Field{Name: f.Value, Type: params[i]})
+	}
+
+	b.typ = FunctionOf(params, b.structure)
+}
+
+// assign checks an assignment to the field of a struct
+func (c *checker) assign(stmt *ast.AssignStatement) {
+	left := c.expression(stmt.Target.Left)
+	field, ok := c.field(stmt.Target, left)
+	value := c.expression(stmt.Value)
+	if ok && !value.AssignableTo(field) {
+		c.errorf(stmt.Value.Pos(), "cannot use %s as %s in assignment to %s.%s", value, field, left, stmt.Target.Field.Value)
+	}
+}
+
+// returned records a value returned by the enclosing function
+func (c *checker) returned(value *Type, pos token.Position) {
+	if len(c.enclosing) == 0 {
+		return
+	}
+
+	fn := c.enclosing[len(c.enclosing)-1]
+	fn.returns = append(fn.returns, value)
+	if fn.result != nil && !value.AssignableTo(fn.result) {
+		c.errorf(pos, "cannot return %s from a function returning %s", value, fn.result)
+	}
+}
+
+// function checks a function literal and returns its type, self is the let
+// binding naming it, if any
+func (c *checker) function(fn *ast.FunctionLiteral, self *binding) *Type {
+	params := make([]*Type, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = AnyType
+		if param.Type != nil {
+			params[i] = c.annotation(param.Type)
+		}
+	}
+
+	current := &function{}
+	result := AnyType
+	if fn.ReturnType != nil {
+		current.result = c.annotation(fn.ReturnType)
+		result = current.result
+	}
+
+	typ := FunctionOf(params, result)
+	if self != nil && !self.declared {
+		self.typ = typ
+	}
+
+	// Only a function bound by let refers to itself by name
+	name := ""
+	if self != nil {
+		name = fn.Name
+	}
+
+	c.names.Enter(name, self)
+	for i, param := range fn.Parameters {
+		c.define(param.Value).typ = params[i]
+	}
+
+	c.enclosing = append(c.enclosing, current)
+	value := c.statements(fn.Body.Statements)
+
+	// The value of the last statement is returned, unless it returned already
+	stmts := fn.Body.Statements
+	if len(stmts) == 0 {
+		c.returned(value, fn.Body.Pos())
+	} else if _, ok := stmts[len(stmts)-1].(*ast.ReturnStatement); !ok {
+		c.returned(value, stmts[len(stmts)-1].Pos())
+	}
+
+	c.enclosing = c.enclosing[:len(c.enclosing)-1]
+	c.names.Leave()
+
+	if current.result == nil {
+		typ.Return = current.returns[0]
+		for _, r := range current.returns[1:] {
+			typ.Return = unify(typ.Return, r)
+		}
+	}
+
+	return typ
+}
+
+// =============================================================================
+// Expressions
+// =============================================================================
+
+func (c *checker) expression(exp ast.Expression) *Type {
+	// Expressions that failed to parse are nil
+	if exp == nil || reflect.ValueOf(exp).IsNil() {
+		return AnyType
+	}
+
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return IntType
+
+	case *ast.FloatLiteral:
+		return FloatType
+
+	case *ast.StringLiteral:
+		return StringType
+
+	case *ast.Boolean:
+		return BoolType
+
+	case *ast.Identifier:
+		return c.resolve(exp.Value)
+
+	case *ast.SelectorExpression:
+		if _, ok := c.builtinName(exp); ok {
+			return FunctionOf(nil, AnyType)
+		}
+
+		field, _ := c.field(exp, c.expression(exp.Left))
+		return field
+
+	case *ast.PrefixExpression:
+		right := c.expression(exp.Right)
+		if exp.Operator == "!" {
+			return BoolType
+		}
+
+		if right.Kind != Any && !isNumeric(right) {
+			c.errorf(exp.Pos(), "unsupported operand type for %s: %s", exp.Operator, right)
+			return AnyType
+		}
+		return right
+
+	case *ast.InfixExpression:
+		return c.infix(exp)
+
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.statements(exp.Consequence.Statements)
+		if exp.Alternative == nil {
+			return unify(consequence, NullType)
+		}
+		return unify(consequence, c.statements(exp.Alternative.Statements))
+
+	case *ast.FunctionLiteral:
+		return c.function(exp, nil)
+
+	case *ast.CallExpression:
+		return c.call(exp)
+
+	case *ast.IndexExpression:
+		return c.index(exp)
+
+	case *ast.SliceExpression:
+		left := c.expression(exp.Left)
+		for _, bound := range []ast.Expression{exp.Start, exp.End} {
+			if bound == nil {
+				continue
+			}
+			if t := c.expression(bound); t.Kind != Any && t.Kind != Int {
+				c.errorf(bound.Pos(), "slice index must be int, got %s", t)
+			}
+		}
+
+		switch left.Kind {
+		case Any, Array, String:
+			return left
+		default:
+			c.errorf(exp.Pos(), "slice operator not supported: %s", left)
+			return AnyType
+		}
+
+	case *ast.ArrayLiteral:
+		if len(exp.Elements) == 0 {
+			return ArrayOf(AnyType)
+		}
+
+		elem := c.expression(exp.Elements[0])
+		for _, el := range exp.Elements[1:] {
+			elem = unify(elem, c.expression(el))
+		}
+		return ArrayOf(elem)
+
+	case *ast.HashLiteral:
+		if len(exp.Keys) == 0 {
+			return HashOf(AnyType, AnyType)
+		}
+
+		var key, value *Type
+		for _, k := range exp.Keys {
+			kt, vt := c.expression(k), c.expression(exp.Pairs[k])
+			if !isHashable(kt) {
+				c.errorf(k.Pos(), "unusable as hash key: %s", kt)
+				kt = AnyType
+			}
+
+			if key == nil {
+				key, value = kt, vt
+				continue
+			}
+			key, value = unify(key, kt), unify(value, vt)
+		}
+		return HashOf(key, value)
+	}
+
+	return AnyType
+}
+
+func (c *checker) infix(exp *ast.InfixExpression) *Type {
+	left, right := c.expression(exp.Left), c.expression(exp.Right)
+
+	switch exp.Operator {
+	case "==", "!=":
+		return BoolType
+
+	case "<", ">":
+		if left.Kind != Any && right.Kind != Any &&
+			!(isNumeric(left) && isNumeric(right)) && !(left.Kind == String && right.Kind == String) {
+			c.errorf(exp.Pos(), "cannot compare %s and %s with %s", left, right, exp.Operator)
+		}
+		return BoolType
+	}
+
+	switch {
+	case isNumeric(left) && isNumeric(right):
+		if left.Kind == Int && right.Kind == Int {
+			return IntType
+		}
+		return FloatType
+
+	case left.Kind == String && right.Kind == String && exp.Operator == "+":
+		return StringType
+
+	case left.Kind == Any || right.Kind == Any:
+		// The known operand must still support the operator with something
+		known := left
+		if known.Kind == Any {
+			known = right
+		}
+		if known.Kind == Any || isNumeric(known) || known.Kind == String && exp.Operator == "+" {
+			return AnyType
+		}
+	}
+
+	c.errorf(exp.Pos(), "unsupported operand types for %s: %s and %s", exp.Operator, left, right)
+	return AnyType
+}
+
+func (c *checker) call(exp *ast.CallExpression) *Type {
+	args := make([]*Type, len(exp.Arguments))
+	for i, arg := range exp.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	if name, ok := c.builtinName(exp.Function); ok {
+		return c.callBuiltin(exp, name, args)
+	}
+
+	callee := c.expression(exp.Function)
+	switch callee.Kind {
+	case Any:
+		return AnyType
+	case Function:
+	default:
+		c.errorf(exp.Pos(), "cannot call %s", callee)
+		return AnyType
+	}
+
+	if callee.Params == nil {
+		return callee.Return
+	}
+
+	name := "function"
+	if ident, ok := exp.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	if len(args) != len(callee.Params) {
+		c.errorf(exp.Pos(), "wrong number of arguments for %s: want=%d, got=%d", name, len(callee.Params), len(args))
+		return callee.Return
+	}
+
+	for i, arg := range args {
+		if !arg.AssignableTo(callee.Params[i]) {
+			c.errorf(exp.Arguments[i].Pos(), "cannot use %s as %s in argument %d of %s", arg, callee.Params[i], i+1, name)
+		}
+	}
+
+	return callee.Return
+}
+
+// builtinName returns the name of the builtin an expression refers to. As in
+// the compiler, a variable shadows any namespace with the same name.
+func (c *checker) builtinName(exp ast.Expression) (string, bool) {
+	var name string
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		name = exp.Value
+	case *ast.SelectorExpression:
+		qualified, ok := exp.QualifiedName()
+		if !ok {
+			return "", false
+		}
+		if sym, ok := c.names.Resolve(exp.Left.String()); ok && sym.Scope != compiler.BuiltinScope {
+			return "", false
+		}
+		name = qualified
+	default:
+		return "", false
+	}
+
+	sym, ok := c.names.Resolve(name)
+	if !ok || sym.Scope != compiler.BuiltinScope {
+		return "", false
+	}
+
+	return sym.Name, true
+}
+
+func (c *checker) callBuiltin(exp *ast.CallExpression, name string, args []*Type) *Type {
+	b := c.builtins[name]
+	if len(args) < b.MinArgs || b.MaxArgs != object.Variadic && len(args) > b.MaxArgs {
+		want := fmt.Sprintf("%d", b.MinArgs)
+		switch {
+		case b.MaxArgs == object.Variadic:
+			want += " or more"
+		case b.MaxArgs != b.MinArgs:
+			want += fmt.Sprintf(" to %d", b.MaxArgs)
+		}
+
+		c.errorf(exp.Pos(), "wrong number of arguments for %s: want=%s, got=%d", name, want, len(args))
+		return AnyType
+	}
+
+	sig, ok := builtinSignatures[name]
+	if !ok {
+		return AnyType
+	}
+
+	for i, e := range sig.params {
+		if i < len(args) && !e.accepts(args[i]) {
+			c.errorf(exp.Arguments[i].Pos(), "argument %d to %s must be %s, got %s", i+1, name, e, args[i])
+		}
+	}
+
+	// Arguments left out are passed to the result as unknown
+	for len(args) < len(sig.params) {
+		args = append(args, AnyType)
+	}
+	return sig.result(args)
+}
+
+// field returns the type of the field a selector refers to in a value of type
+// left, reporting fields missing from a struct and values without fields. The
+// type is known when ok.
+func (c *checker) field(exp *ast.SelectorExpression, left *Type) (typ *Type, ok bool) {
+	switch left.Kind {
+	case Any:
+		return AnyType, false
+
+	case Struct:
+		field, ok := left.Field(exp.Field.Value)
+		if !ok {
+			c.errorf(exp.Pos(), "unknown field %s in %s", exp.Field.Value, left)
+			return AnyType, false
+		}
+		return field, true
+
+	default:
+		c.errorf(exp.Pos(), "field access not supported: %s", left)
+		return AnyType, false
+	}
+}
+
+func (c *checker) index(exp *ast.IndexExpression) *Type {
+	left, index := c.expression(exp.Left), c.expression(exp.Index)
+
+	switch left.Kind {
+	case Any:
+		return AnyType
+
+	case Array:
+		if index.Kind != Any && index.Kind != Int {
+			c.errorf(exp.Index.Pos(), "array index must be int, got %s", index)
+		}
+		return left.Elem
+
+	case Hash:
+		if !isHashable(index) {
+			c.errorf(exp.Index.Pos(), "unusable as hash key: %s", index)
+		} else if !index.AssignableTo(left.Key) {
+			c.errorf(exp.Index.Pos(), "cannot use %s as key of %s", index, left)
+		}
+		return left.Elem
+
+	default:
+		c.errorf(exp.Pos(), "index operator not supported: %s", left)
+		return AnyType
+	}
+}
+
+// =============================================================================
+// Names and annotations
+// =============================================================================
+
+// define binds a name of unknown type in the current table
+func (c *checker) define(name string) *binding {
+	b := &binding{typ: AnyType}
+	c.names.Define(name, b)
+	return b
+}
+
+// resolve returns the type of a name, unknown names being left to the compiler
+func (c *checker) resolve(name string) *Type {
+	sym, ok := c.names.Resolve(name)
+	if !ok {
+		return AnyType
+	}
+
+	if sym.Scope == compiler.BuiltinScope {
+		return FunctionOf(nil, AnyType)
+	}
+
+	if b := c.lookup(sym); b != nil {
+		return b.typ
+	}
+	return AnyType
+}
+
+// lookup returns the binding of a symbol resolved in the current table
+func (c *checker) lookup(sym compiler.Symbol) *binding {
+	b, _ := c.names.Lookup(sym).(*binding)
+	return b
+}
+
+// annotation returns the type written in an annotation
+func (c *checker) annotation(te ast.TypeExpression) *Type {
+	switch te := te.(type) {
+	case *ast.NamedType:
+		if t, ok := namedTypes[te.Name]; ok {
+			return t
+		}
+
+		// Structs are named by the binding they were declared with
+		if sym, ok := c.names.Resolve(te.Name); ok {
+			if b := c.lookup(sym); b != nil && b.structure != nil {
+				return b.structure
+			}
+		}
+		c.errorf(te.Pos(), "unknown type %s", te.Name)
+
+	case *ast.ArrayType:
+		return ArrayOf(c.annotation(te.Element))
+
+	case *ast.HashType:
+		key := c.annotation(te.Key)
+		if !isHashable(key) {
+			c.errorf(te.Key.Pos(), "unusable as hash key: %s", key)
+			key = AnyType
+		}
+		return HashOf(key, c.annotation(te.Value))
+
+	case *ast.FunctionType:
+		params := make([]*Type, len(te.Parameters))
+		for i, p := range te.Parameters {
+			params[i] = c.annotation(p)
+		}
+
+		result := AnyType
+		if te.Return != nil {
+			result = c.annotation(te.Return)
+		}
+		return FunctionOf(params, result)
+	}
+
+	return AnyType
+}
+
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errors = append(c.errors, compiler.Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
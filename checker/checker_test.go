@@ -0,0 +1,187 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Programs without type errors
+		{`let x = 1; let y = x * 2.5; puts(y > 1, "a" + "b", -x, !x);`, nil},
+		{`let add = fn(a, b) { a + b }; add(1, 2); add("a", "b");`, nil},
+		{`let f = fn(x) { x + 1 }; f(len([1, 2]));`, nil},
+		{`let h = {"a": 1}; h["a"] + 1; [1, 2][0] * 3; "abc"[1:];`, nil},
+		{`let fib = fn(n: int): int { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10);`, nil},
+		{`let n: float = 1; let xs: [any] = [1, "a"]; let h: {string: [int]} = {"a": [1]};`, nil},
+		{`let apply = fn(f: fn(int): int, x: int): int { f(x) }; apply(fn(n) { n * 2 }, 3);`, nil},
+
+		// Operators
+		{`1 + "a"`, []string{"1:3: unsupported operand types for +: int and string"}},
+		{`"a" - "b"`, []string{"1:5: unsupported operand types for -: string and string"}},
+		{`let x = true; x * 2`, []string{"1:17: unsupported operand types for *: bool and int"}},
+		{`-"a"; -[1]`, []string{
+			"1:1: unsupported operand type for -: string",
+			"1:7: unsupported operand type for -: [int]",
+		}},
+		{`1 < "a"; [1] > [2]`, []string{
+			"1:3: cannot compare int and string with <",
+			"1:14: cannot compare [int] and [int] with >",
+		}},
+		{`let f = fn(x) { x }; f(1) + true; [1] + f(2)`, []string{
+			"1:27: unsupported operand types for +: any and bool",
+			"1:39: unsupported operand types for +: [int] and any",
+		}},
+
+		// Inference through bindings, closures and results
+		{`let s = "a"; let f = fn() { s * 2 }; f`, []string{"1:31: unsupported operand types for *: string and int"}},
+		{`let f = fn() { "a" }; f() - 1`, []string{"1:27: unsupported operand types for -: string and int"}},
+		{`let x = if (true) { 1 } else { 2 }; x + "a"`, []string{"1:39: unsupported operand types for +: int and string"}},
+		{`let x = if (true) { 1 }; x + "a"`, nil},
+
+		// Calls
+		{`let add = fn(a, b) { a + b }; add(1)`, []string{"1:34: wrong number of arguments for add: want=2, got=1"}},
+		{`let f = fn(a: int) { a }; f("a"); f(1.5)`, []string{
+			"1:29: cannot use string as int in argument 1 of f",
+			"1:37: cannot use float as int in argument 1 of f",
+		}},
+		{`1(); let s = "a"; s(1)`, []string{"1:2: cannot call int", "1:20: cannot call string"}},
+		{`fn(a) { a }()`, []string{"1:12: wrong number of arguments for function: want=1, got=0"}},
+
+		// Builtins
+		{`len(1); len("a", "b"); first("a"); upper(1)`, []string{
+			"1:5: argument 1 to len must be string, array or hash, got int",
+			"1:12: wrong number of arguments for len: want=1, got=2",
+			"1:30: argument 1 to first must be array, got string",
+			"1:42: argument 1 to upper must be string, got int",
+		}},
+		{`first([1, 2]) + "a"; len("a") + "b"; math.abs("a")`, []string{
+			"1:15: unsupported operand types for +: int and string",
+			"1:31: unsupported operand types for +: int and string",
+			"1:47: argument 1 to math.abs must be float, got string",
+		}},
+		{`range(1, 2, 3, 4); puts()`, []string{"1:6: wrong number of arguments for range: want=1 to 3, got=4"}},
+
+		// Indexing
+		{`1[0]; [1]["a"]; {"a": 1}[[fn() {}]]`, []string{
+			"1:2: index operator not supported: int",
+			"1:11: array index must be int, got string",
+			"1:26: unusable as hash key: [fn(): null]",
+		}},
+		{`let h = {"a": 1}; h[1]; {[1]: 1, 1.5: 2}`, []string{
+			"1:21: cannot use int as key of {string: int}",
+			"1:34: unusable as hash key: float",
+		}},
+		{`1[1:]; [1]["a":]`, []string{
+			"1:2: slice operator not supported: int",
+			"1:12: slice index must be int, got string",
+		}},
+
+		// Annotations
+		{`let x: int = "a"; let y: [string] = [1]; let z: {string: int} = {"a": "b"}`, []string{
+			"1:14: cannot use string as int in let x",
+			"1:37: cannot use [int] as [string] in let y",
+			"1:65: cannot use {string: string} as {string: int} in let z",
+		}},
+		{`let x: integer = 1; let h: {[int]: bool} = {}; let g: {float: int} = {}`, []string{
+			"1:8: unknown type integer",
+			"1:56: unusable as hash key: float",
+		}},
+		{`let x: int = 1; let f = fn(): int { x }; f() + "a"`, []string{"1:46: unsupported operand types for +: int and string"}},
+		{`fn(): int { "a" }; fn(): int { return "a"; 1 }; fn(): string {}`, []string{
+			"1:13: cannot return string from a function returning int",
+			"1:32: cannot return string from a function returning int",
+			"1:62: cannot return null from a function returning string",
+		}},
+		{`let f: fn(int): int = fn(s: string): string { s }`, []string{
+			"1:23: cannot use fn(string): string as fn(int): int in let f",
+		}},
+		{`let f = fn(s: string) { s - 1 }`, []string{"1:27: unsupported operand types for -: string and int"}},
+
+		// Structs
+		{`struct Point { x: int, y: int }; let p = Point(1, 2); p.x = p.y + 1; let q: Point = p; let f = fn(p: Point): int { p.x }; f(q) * 2;`, nil},
+		{`struct Point { x: int, y }; Point(1); Point("a", 2); let p = Point(1, 2); p.z; p.x = "a"; p.x + "b"`, []string{
+			"1:34: wrong number of arguments for Point: want=2, got=1",
+			"1:45: cannot use string as int in argument 1 of Point",
+			"1:76: unknown field z in Point",
+			"1:86: cannot use string as int in assignment to Point.x",
+			"1:95: unsupported operand types for +: int and string",
+		}},
+		{`let n = 1; n.x; n.x = 2; "s".len`, []string{
+			"1:13: field access not supported: int",
+			"1:18: field access not supported: int",
+			"1:29: field access not supported: string",
+		}},
+		{`struct Node { value: int, next: Node }; let f = fn(n: Node): Node { n.next }; let g: Point = 1;`, []string{
+			"1:86: unknown type Point",
+		}},
+		{`struct A {}; struct B {}; let a: A = B();`, []string{"1:39: cannot use B as A in let a"}},
+		{`let math = 1; math.abs(1)`, []string{"1:19: field access not supported: int"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		got := []string{}
+		err := Check(program, object.NewRegistry())
+		if err != nil {
+			errs, ok := err.(compiler.ErrorList)
+			if !ok {
+				t.Fatalf("expected an ErrorList for %q, got=%T", tt.input, err)
+			}
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignableTo(t *testing.T) {
+	fnIntInt := FunctionOf([]*Type{IntType}, IntType)
+	fnAnyInt := FunctionOf([]*Type{AnyType}, IntType)
+	point := StructOf("Point", []Field{{Name: "x", Type: IntType}})
+
+	tests := []struct {
+		from, to *Type
+		expected bool
+	}{
+		{IntType, IntType, true},
+		{IntType, FloatType, true},
+		{FloatType, IntType, false},
+		{StringType, AnyType, true},
+		{AnyType, StringType, true},
+		{NullType, IntType, false},
+		{ArrayOf(IntType), ArrayOf(FloatType), true},
+		{ArrayOf(StringType), ArrayOf(IntType), false},
+		{HashOf(StringType, IntType), HashOf(StringType, AnyType), true},
+		{fnAnyInt, fnIntInt, true},
+		{FunctionOf([]*Type{StringType}, IntType), fnIntInt, false},
+		{FunctionOf(nil, AnyType), fnIntInt, true},
+		{FunctionOf([]*Type{IntType, IntType}, IntType), fnIntInt, false},
+		{point, point, true},
+		{point, StructOf("Point", []Field{{Name: "x", Type: IntType}}), false},
+		{point, AnyType, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.AssignableTo(tt.to); got != tt.expected {
+			t.Errorf("%s assignable to %s wrong. want=%t, got=%t", tt.from, tt.to, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package checker
+
+import "strings"
+
+// Kind is the kind of a static type
+type Kind int
+
+// Kinds of types. Any is the type of values the checker knows nothing about,
+// which are accepted everywhere.
+const (
+	Any Kind = iota
+	Int
+	Float
+	String
+	Bool
+	Null
+	Array
+	Hash
+	Function
+	Struct
+)
+
+// Type is the static type of an expression
+type Type struct {
+	Kind   Kind
+	Elem   *Type   // element of an array, value of a hash
+	Key    *Type   // key of a hash
+	Params []*Type // parameters of a function, nil when unknown
+	Return *Type   // result of a function
+	Name   string  // name of a struct
+	Fields []Field // fields of a struct
+}
+
+// Field is a field of a struct type
+type Field struct {
+	Name string
+	Type *Type
+}
+
+// Types without parameters
+var (
+	AnyType    = &Type{Kind: Any}
+	IntType    = &Type{Kind: Int}
+	FloatType  = &Type{Kind: Float}
+	StringType = &Type{Kind: String}
+	BoolType   = &Type{Kind: Bool}
+	NullType   = &Type{Kind: Null}
+)
+
+// namedTypes are the types written by name in annotations
+var namedTypes = map[string]*Type{
+	"any":    AnyType,
+	"int":    IntType,
+	"float":  FloatType,
+	"string": StringType,
+	"bool":   BoolType,
+	"null":   NullType,
+}
+
+// ArrayOf returns the type of arrays of elem
+func ArrayOf(elem *Type) *Type {
+	return &Type{Kind: Array, Elem: elem}
+}
+
+// HashOf returns the type of hashes from key to value
+func HashOf(key, value *Type) *Type {
+	return &Type{Kind: Hash, Key: key, Elem: value}
+}
+
+// FunctionOf returns the type of functions taking params and returning result.
+// Nil params stand for a function accepting any arguments.
+func FunctionOf(params []*Type, result *Type) *Type {
+	return &Type{Kind: Function, Params: params, Return: result}
+}
+
+// StructOf returns a new struct type. Struct types are only equal to
+// themselves, as every declaration creates a distinct type.
+func StructOf(name string, fields []Field) *Type {
+	return &Type{Kind: Struct, Name: name, Fields: fields}
+}
+
+// Field returns the type of the named field of a struct
+func (t *Type) Field(name string) (*Type, bool) {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f.Type, true
+		}
+	}
+
+	return nil, false
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case String:
+		return "string"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	case Array:
+		return "[" + t.Elem.String() + "]"
+	case Hash:
+		return "{" + t.Key.String() + ": " + t.Elem.String() + "}"
+	case Function:
+		params := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = p.String()
+		}
+		if t.Params == nil {
+			params = []string{"..."}
+		}
+		return "fn(" + strings.Join(params, ", ") + "): " + t.Return.String()
+	case Struct:
+		return t.Name
+	default:
+		return "any"
+	}
+}
+
+// Equal reports whether two types are the same
+func (t *Type) Equal(other *Type) bool {
+	if t.Kind != other.Kind {
+		return false
+	}
+
+	switch t.Kind {
+	case Array:
+		return t.Elem.Equal(other.Elem)
+	case Hash:
+		return t.Key.Equal(other.Key) && t.Elem.Equal(other.Elem)
+	case Function:
+		if (t.Params == nil) != (other.Params == nil) || len(t.Params) != len(other.Params) {
+			return false
+		}
+		for i := range t.Params {
+			if !t.Params[i].Equal(other.Params[i]) {
+				return false
+			}
+		}
+		return t.Return.Equal(other.Return)
+	case Struct:
+		return t == other
+	default:
+		return true
+	}
+}
+
+// AssignableTo reports whether a value of type t may be used where a value of
+// type other is expected. Any is assignable both ways and integers are used as
+// floats, as the VM promotes them.
+func (t *Type) AssignableTo(other *Type) bool {
+	if t.Kind == Any || other.Kind == Any {
+		return true
+	}
+
+	if t.Kind == Int && other.Kind == Float {
+		return true
+	}
+
+	if t.Kind != other.Kind {
+		return false
+	}
+
+	switch t.Kind {
+	case Array:
+		return t.Elem.AssignableTo(other.Elem)
+	case Hash:
+		return t.Key.AssignableTo(other.Key) && t.Elem.AssignableTo(other.Elem)
+	case Function:
+		if t.Params != nil && other.Params != nil {
+			if len(t.Params) != len(other.Params) {
+				return false
+			}
+			for i := range t.Params {
+				if !other.Params[i].AssignableTo(t.Params[i]) {
+					return false
+				}
+			}
+		}
+		return t.Return.AssignableTo(other.Return)
+	case Struct:
+		return t == other
+	default:
+		return true
+	}
+}
+
+// unify returns the type of a value that is either of the given types
+func unify(a, b *Type) *Type {
+	if a.Equal(b) {
+		return a
+	}
+
+	return AnyType
+}
+
+func isNumeric(t *Type) bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// isHashable reports whether values of the type may be hash keys, unknown
+// values included
+func isHashable(t *Type) bool {
+	switch t.Kind {
+	case Any, Int, String, Bool:
+		return true
+	case Array:
+		return isHashable(t.Elem)
+	default:
+		return false
+	}
+}
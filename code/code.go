@@ -44,6 +44,10 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpSlice
+	OpGetField
+	OpSetField
+	OpLessThan
 )
 
 // Definition of the Opcodes used within the virtual stack machine
@@ -61,9 +65,9 @@ var definitions = map[Opcode]*Definition{
 	OpDiv:            {"OpDiv", []int{}},
 	OpTrue:           {"OpTrue", []int{}},
 	OpFalse:          {"OpFalse", []int{}},
-	OpEqual:          {"OpFalse", []int{}},
-	OpNotEqual:       {"OpFalse", []int{}},
-	OpGreaterThan:    {"OpFalse", []int{}},
+	OpEqual:          {"OpEqual", []int{}},
+	OpNotEqual:       {"OpNotEqual", []int{}},
+	OpGreaterThan:    {"OpGreaterThan", []int{}},
 	OpMinus:          {"OpMinus", []int{}},
 	OpBang:           {"OpBang", []int{}},
 	OpJump:           {"OpJump", []int{2}},
@@ -79,10 +83,14 @@ var definitions = map[Opcode]*Definition{
 	OpReturn:         {"OpReturn", []int{}},
 	OpSetLocal:       {"OpSetLocal", []int{1}},
 	OpGetLocal:       {"OpGetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}},
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpSlice:          {"OpSlice", []int{}},
+	OpGetField:       {"OpGetField", []int{2}},
+	OpSetField:       {"OpSetField", []int{2}},
+	OpLessThan:       {"OpLessThan", []int{}},
 }
 
 // Lookup returns the corresponding Opcode for a given byte
@@ -165,6 +173,7 @@ func (ins Instructions) String() string {
 		def, err := Lookup(ins[i])
 		if err != nil {
 			fmt.Fprintf(&out, "Error: %s\n", err)
+			i++
 			continue
 		}
 
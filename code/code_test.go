@@ -36,6 +36,9 @@ func TestInstructionsString(t *testing.T) {
 		Make(OpConstant, 2),
 		Make(OpConstant, 65535),
 		Make(OpClosure, 65535, 255),
+		Make(OpEqual),
+		Make(OpNotEqual),
+		Make(OpGreaterThan),
 	}
 
 	expected := `0000 OpAdd
@@ -43,6 +46,9 @@ func TestInstructionsString(t *testing.T) {
 0003 OpConstant 2
 0006 OpConstant 65535
 0009 OpClosure 65535 255
+0013 OpEqual
+0014 OpNotEqual
+0015 OpGreaterThan
 `
 
 	concatted := Instructions{}
@@ -0,0 +1,27 @@
+package code
+
+import (
+	"sort"
+
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// SourceMapEntry marks the instruction at Offset as compiled from the source at Pos
+type SourceMapEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// SourceMap maps instruction offsets back to source positions. Entries are sorted
+// by offset, and each entry covers every instruction up to the next entry.
+type SourceMap []SourceMapEntry
+
+// Lookup returns the source position of the instruction containing the offset
+func (sm SourceMap) Lookup(offset int) token.Position {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+
+	return sm[i-1].Pos
+}
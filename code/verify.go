@@ -0,0 +1,228 @@
+package code
+
+import "fmt"
+
+// Limits describes the environment a sequence of instructions runs in, which
+// bounds the operands Verify accepts
+type Limits struct {
+	NumConstants int
+	NumBuiltins  int
+	NumLocals    int // zero for the main program, which has no locals
+	NumFree      int // number of free variables captured by the closure being verified
+
+	// Functions maps the index of every compiled function in the constant pool
+	// to the number of free variables it expects. OpClosure may only refer to
+	// these constants, passing the expected number of free variables.
+	Functions map[int]int
+
+	// Returns is set for function bodies, which must return on every path
+	// instead of running past their last instruction
+	Returns bool
+}
+
+// VerifyError reports the instruction that failed verification
+type VerifyError struct {
+	Offset  int
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%04d: %s", e.Offset, e.Message)
+}
+
+// Verify checks that the instructions can be executed without the vm reading
+// past them or outside of its stack, constants, locals or free variables.
+// Every opcode must be defined and complete, jumps must land on the start of an
+// instruction and, on every path through the instructions, each instruction
+// must see the same number of values on the stack and never pop more values
+// than have been pushed.
+func Verify(ins Instructions, limits Limits) error {
+	// Decode every instruction first, so that jump targets can be checked
+	starts := make(map[int]bool)
+	for offset := 0; offset < len(ins); {
+		def, err := Lookup(ins[offset])
+		if err != nil {
+			return &VerifyError{Offset: offset, Message: err.Error()}
+		}
+
+		width := 1
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+
+		if offset+width > len(ins) {
+			return &VerifyError{Offset: offset, Message: fmt.Sprintf("%s is missing operands", def.Name)}
+		}
+
+		starts[offset] = true
+		offset += width
+	}
+
+	v := &verifier{ins: ins, limits: limits, starts: starts, depths: make(map[int]int)}
+	return v.run()
+}
+
+type verifier struct {
+	ins    Instructions
+	limits Limits
+	starts map[int]bool // offsets at which instructions start
+	depths map[int]int  // stack depth before each instruction reached so far
+	queue  []int        // offsets of reached instructions still to be checked
+}
+
+// run follows every path through the instructions, starting with an empty stack
+func (v *verifier) run() error {
+	if len(v.ins) == 0 {
+		if v.limits.Returns {
+			return &VerifyError{Offset: 0, Message: "function does not return"}
+		}
+		return nil
+	}
+
+	v.depths[0] = 0
+	v.queue = []int{0}
+
+	for len(v.queue) > 0 {
+		offset := v.queue[len(v.queue)-1]
+		v.queue = v.queue[:len(v.queue)-1]
+
+		if err := v.check(offset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check verifies a single instruction and queues the instructions that may follow it
+func (v *verifier) check(offset int) error {
+	op := Opcode(v.ins[offset])
+	def, _ := Lookup(byte(op))
+	operands, read := ReadOperands(def, v.ins[offset+1:])
+	next := offset + 1 + read
+
+	fail := func(format string, a ...interface{}) error {
+		return &VerifyError{Offset: offset, Message: fmt.Sprintf(format, a...)}
+	}
+
+	switch op {
+	case OpConstant, OpGetField, OpSetField:
+		if operands[0] >= v.limits.NumConstants {
+			return fail("constant index %d out of range, %d constants", operands[0], v.limits.NumConstants)
+		}
+
+	case OpGetLocal, OpSetLocal:
+		if operands[0] >= v.limits.NumLocals {
+			return fail("local index %d out of range, %d locals", operands[0], v.limits.NumLocals)
+		}
+
+	case OpGetFree:
+		if operands[0] >= v.limits.NumFree {
+			return fail("free variable index %d out of range, %d free variables", operands[0], v.limits.NumFree)
+		}
+
+	case OpGetBuiltin:
+		if operands[0] >= v.limits.NumBuiltins {
+			return fail("builtin index %d out of range, %d builtins", operands[0], v.limits.NumBuiltins)
+		}
+
+	case OpClosure:
+		numFree, ok := v.limits.Functions[operands[0]]
+		if !ok {
+			return fail("constant %d is not a function", operands[0])
+		}
+		if operands[1] != numFree {
+			return fail("closure over constant %d captures %d free variables, want %d", operands[0], operands[1], numFree)
+		}
+
+	case OpHash:
+		// The operand counts keys and values, which are pushed in pairs
+		if operands[0]%2 != 0 {
+			return fail("%s of %d values is missing a value for its last key", def.Name, operands[0])
+		}
+
+	case OpCurrentClosure:
+		if !v.limits.Returns {
+			return fail("%s outside of a function", def.Name)
+		}
+	}
+
+	pop, push := stackEffect(op, operands)
+	depth := v.depths[offset]
+	if depth < pop {
+		return fail("%s pops %d values from a stack of %d", def.Name, pop, depth)
+	}
+	depth += push - pop
+
+	switch op {
+	case OpReturnValue, OpReturn:
+		if !v.limits.Returns {
+			return fail("%s outside of a function", def.Name)
+		}
+		return nil
+
+	case OpJump:
+		return v.reach(offset, operands[0], depth)
+
+	case OpJumpNotTruthy:
+		if err := v.reach(offset, operands[0], depth); err != nil {
+			return err
+		}
+	}
+
+	return v.reach(offset, next, depth)
+}
+
+// reach records that the instruction at target is reached from offset with the stack at depth
+func (v *verifier) reach(offset, target, depth int) error {
+	if target == len(v.ins) {
+		if v.limits.Returns {
+			return &VerifyError{Offset: offset, Message: "function does not return"}
+		}
+		return nil
+	}
+
+	if !v.starts[target] {
+		return &VerifyError{Offset: offset, Message: fmt.Sprintf("jump target %d is not the start of an instruction", target)}
+	}
+
+	if known, ok := v.depths[target]; ok {
+		if known != depth {
+			return &VerifyError{Offset: target, Message: fmt.Sprintf("stack depth %d differs from %d on another path", depth, known)}
+		}
+		return nil
+	}
+
+	v.depths[target] = depth
+	v.queue = append(v.queue, target)
+	return nil
+}
+
+// stackEffect returns the number of values an instruction pops from and pushes to the stack
+func stackEffect(op Opcode, operands []int) (pop, push int) {
+	switch op {
+	case OpConstant, OpTrue, OpFalse, OpNull, OpGetGlobal, OpGetLocal, OpGetBuiltin, OpGetFree, OpCurrentClosure:
+		return 0, 1
+	case OpAdd, OpSub, OpMul, OpDiv, OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpIndex:
+		return 2, 1
+	case OpMinus, OpBang:
+		return 1, 1
+	case OpPop, OpJumpNotTruthy, OpSetGlobal, OpSetLocal, OpReturnValue:
+		return 1, 0
+	case OpArray, OpHash:
+		return operands[0], 1
+	case OpCall:
+		// The function is popped along with its arguments and replaced by the result
+		return operands[0] + 1, 1
+	case OpClosure:
+		return operands[1], 1
+	case OpSlice:
+		return 3, 1
+	case OpGetField:
+		return 1, 1
+	case OpSetField:
+		return 2, 0
+	default:
+		return 0, 0
+	}
+}
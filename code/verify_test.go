@@ -0,0 +1,94 @@
+package code
+
+import "testing"
+
+func concat(instructions ...[]byte) Instructions {
+	out := Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	main := Limits{NumConstants: 2, NumBuiltins: 1, Functions: map[int]int{1: 1}}
+	function := Limits{NumConstants: 2, NumLocals: 1, NumFree: 1, Functions: map[int]int{1: 1}, Returns: true}
+
+	tests := []struct {
+		name     string
+		ins      Instructions
+		limits   Limits
+		expected string
+	}{
+		{
+			"valid main program",
+			concat(Make(OpTrue), Make(OpJumpNotTruthy, 10), Make(OpConstant, 0), Make(OpJump, 11), Make(OpNull), Make(OpPop)),
+			main,
+			"",
+		},
+		{
+			"valid function",
+			concat(Make(OpGetLocal, 0), Make(OpGetFree, 0), Make(OpAdd), Make(OpReturnValue)),
+			function,
+			"",
+		},
+		{
+			"closure creation",
+			concat(Make(OpGetBuiltin, 0), Make(OpClosure, 1, 1), Make(OpPop)),
+			main,
+			"",
+		},
+		{
+			"field access",
+			concat(Make(OpGetGlobal, 0), Make(OpConstant, 0), Make(OpSetField, 1), Make(OpGetGlobal, 0), Make(OpGetField, 1), Make(OpPop)),
+			main,
+			"",
+		},
+		{"empty main program", Instructions{}, main, ""},
+		{"empty function", Instructions{}, function, "0000: function does not return"},
+		{"undefined opcode", Instructions{255}, main, "0000: opcode 255 undefined"},
+		{"missing operands", concat(Make(OpTrue), Make(OpConstant, 1)[:2]), main, "0001: OpConstant is missing operands"},
+		{"constant out of range", concat(Make(OpConstant, 2), Make(OpPop)), main, "0000: constant index 2 out of range, 2 constants"},
+		{"builtin out of range", concat(Make(OpGetBuiltin, 1), Make(OpPop)), main, "0000: builtin index 1 out of range, 1 builtins"},
+		{"local in main program", concat(Make(OpGetLocal, 0), Make(OpPop)), main, "0000: local index 0 out of range, 0 locals"},
+		{"local out of range", concat(Make(OpGetLocal, 1), Make(OpReturnValue)), function, "0000: local index 1 out of range, 1 locals"},
+		{"free out of range", concat(Make(OpGetFree, 1), Make(OpReturnValue)), function, "0000: free variable index 1 out of range, 1 free variables"},
+		{"closure over non-function", concat(Make(OpClosure, 0, 0), Make(OpPop)), main, "0000: constant 0 is not a function"},
+		{"closure free count", concat(Make(OpClosure, 1, 0), Make(OpPop)), main, "0000: closure over constant 1 captures 0 free variables, want 1"},
+		{"jump into operands", concat(Make(OpJump, 1), Make(OpNull)), main, "0000: jump target 1 is not the start of an instruction"},
+		{"jump past end", concat(Make(OpJump, 10)), main, "0000: jump target 10 is not the start of an instruction"},
+		{"stack underflow", concat(Make(OpConstant, 0), Make(OpAdd)), main, "0003: OpAdd pops 2 values from a stack of 1"},
+		{"field name out of range", concat(Make(OpGetGlobal, 0), Make(OpGetField, 2), Make(OpPop)), main, "0003: constant index 2 out of range, 2 constants"},
+		{"set field underflow", concat(Make(OpConstant, 0), Make(OpSetField, 1)), main, "0003: OpSetField pops 2 values from a stack of 1"},
+		{"odd hash operand", concat(Make(OpConstant, 0), Make(OpHash, 1), Make(OpPop)), main, "0003: OpHash of 1 values is missing a value for its last key"},
+		{"call underflow", concat(Make(OpCall, 1), Make(OpPop)), main, "0000: OpCall pops 2 values from a stack of 0"},
+		{
+			"unbalanced branches",
+			concat(Make(OpTrue), Make(OpJumpNotTruthy, 6), Make(OpNull), Make(OpNull), Make(OpPop)),
+			main,
+			"0006: stack depth 2 differs from 0 on another path",
+		},
+		{"return in main program", concat(Make(OpNull), Make(OpReturnValue)), main, "0001: OpReturnValue outside of a function"},
+		{"current closure in main program", concat(Make(OpCurrentClosure), Make(OpPop)), main, "0000: OpCurrentClosure outside of a function"},
+		{"function without return", concat(Make(OpGetLocal, 0), Make(OpPop)), function, "0002: function does not return"},
+		{
+			"function returning on one path",
+			concat(Make(OpTrue), Make(OpJumpNotTruthy, 5), Make(OpReturn), Make(OpNull), Make(OpPop)),
+			function,
+			"0006: function does not return",
+		},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.ins, tt.limits)
+
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		case tt.expected != "" && err == nil:
+			t.Errorf("%s: expected error %q", tt.name, tt.expected)
+		case tt.expected != "" && err.Error() != tt.expected:
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
@@ -2,17 +2,20 @@ package compiler
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/lukeomalley/monkey_lang/ast"
 	"github.com/lukeomalley/monkey_lang/code"
 	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/token"
 )
 
 // Bytecode output of compiler used by the VM
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    code.SourceMap
+	GlobalNames  []string // names of the globals, indexed by OpGetGlobal and OpSetGlobal operands
+	BuiltinNames []string // names of the builtins, indexed by OpGetBuiltin operands
 }
 
 // Bytecode constructs a new bytecode object
@@ -20,6 +23,9 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		GlobalNames:  c.symbolTable.DefinedNames(),
+		BuiltinNames: c.symbolTable.BuiltinNames(),
 	}
 }
 
@@ -35,6 +41,8 @@ type Compiler struct {
 	symbolTable *SymbolTable
 	scopes      []CompilationScope
 	scopeIndex  int
+	pos         token.Position // position of the node being compiled
+	errors      ErrorList      // errors found so far, compilation goes on after each
 }
 
 // CompilationScope stores scoped instructions for block level declarations
@@ -42,10 +50,16 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	sourceMap           code.SourceMap
 }
 
 // New cnstructs a new compiler
 func New() *Compiler {
+	return NewWithBuiltins(object.NewRegistry())
+}
+
+// NewWithBuiltins constructs a compiler that resolves builtin functions from the given registry
+func NewWithBuiltins(builtins *object.Registry) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
@@ -53,8 +67,8 @@ func New() *Compiler {
 	}
 
 	symbolTable := NewSymbolTable()
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
+	for i, b := range builtins.Builtins() {
+		symbolTable.DefineBuiltin(i, b.Name)
 	}
 
 	return &Compiler{
@@ -73,19 +87,42 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	return compiler
 }
 
-// Compile traverses the AST and emits bytecode
+// Compile traverses the AST and emits bytecode. Compilation goes on after an
+// error, so every error of the program is returned, as an ErrorList.
 func (c *Compiler) Compile(node ast.Node) error {
+	c.errors = nil
+
+	if err := c.compile(node); err != nil {
+		return err
+	}
+
+	if len(c.errors) != 0 {
+		c.errors.Sort()
+		return c.errors
+	}
+
+	return nil
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	// Instructions emitted for the node are attributed to its position in the source map
+	if node != nil && node.Pos().IsValid() {
+		outer := c.pos
+		c.pos = node.Pos()
+		defer func() { c.pos = outer }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
-			err := c.Compile(s)
+			err := c.compile(s)
 			if err != nil {
 				return err
 			}
 		}
 
 	case *ast.ExpressionStatement:
-		err := c.Compile(node.Expression)
+		err := c.compile(node.Expression)
 		if err != nil {
 			return err
 		}
@@ -93,7 +130,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.BlockStatement:
 		for _, s := range node.Statements {
-			err := c.Compile(s)
+			err := c.compile(s)
 			if err != nil {
 				return err
 			}
@@ -103,7 +140,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Define the name within the symbol table
 		symbol := c.symbolTable.Define(node.Name.Value)
 
-		err := c.Compile(node.Value)
+		err := c.compile(node.Value)
 		if err != nil {
 			return err
 		}
@@ -114,38 +151,73 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpSetLocal, symbol.Index)
 		}
 
+	case *ast.StructStatement:
+		symbol := c.symbolTable.Define(node.Name.Value)
+
+		fields := make([]string, len(node.Fields))
+		for i, f := range node.Fields {
+			fields[i] = f.Value
+		}
+
+		structType := &object.StructType{Name: node.Name.Value, Fields: fields}
+		c.emit(code.OpConstant, c.addConstant(structType))
+
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+
+	case *ast.AssignStatement:
+		err := c.compile(node.Target.Left)
+		if err != nil {
+			return err
+		}
+
+		err = c.compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetField, c.addConstant(&object.String{Value: node.Target.Field.Value}))
+
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable: %s", symbol.Name)
+			c.errors = append(c.errors, undefined(node.Pos(), node.Value, c.symbolTable))
+			return nil
 		}
 
 		c.emitSymbol(symbol)
 
-	case *ast.InfixExpression:
-		// "Rewrite" code for less than to reduce instruction set
-		if node.Operator == "<" {
-			// Flip the order of the right and left nodes
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
+	case *ast.SelectorExpression:
+		// Selectors such as math.abs name a builtin, unless a variable shadows
+		// the namespace
+		if name, ok := node.QualifiedName(); ok && !c.isVariable(node.Left) {
+			symbol, ok := c.symbolTable.Resolve(name)
+			if !ok {
+				c.errors = append(c.errors, undefined(node.Left.Pos(), name, c.symbolTable))
+				return nil
 			}
 
-			c.emit(code.OpGreaterThan)
+			c.emitSymbol(symbol)
 			return nil
 		}
 
-		err := c.Compile(node.Left)
+		err := c.compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpGetField, c.addConstant(&object.String{Value: node.Field.Value}))
+
+	case *ast.InfixExpression:
+		err := c.compile(node.Left)
 		if err != nil {
 			return err
 		}
 
-		err = c.Compile(node.Right)
+		err = c.compile(node.Right)
 		if err != nil {
 			return err
 		}
@@ -165,12 +237,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpNotEqual)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			c.errorf(node.Pos(), "unknown operator %s", node.Operator)
 		}
 
 	case *ast.PrefixExpression:
-		err := c.Compile(node.Right)
+		err := c.compile(node.Right)
 		if err != nil {
 			return err
 		}
@@ -181,7 +255,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "-":
 			c.emit(code.OpMinus)
 		default:
-			return fmt.Errorf("unknown prefix operator %s", node.Operator)
+			c.errorf(node.Pos(), "unknown prefix operator %s", node.Operator)
 		}
 	case *ast.IfExpression:
 		/* Example w/ Bytecode:
@@ -196,7 +270,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			OpPop
 		*/
 
-		err := c.Compile(node.Condition)
+		err := c.compile(node.Condition)
 		if err != nil {
 			return err
 		}
@@ -204,15 +278,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Create a conditional jump with a dummy location and store the position to be updated later
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999) // 9999 is a dummy value
 
-		err = c.Compile(node.Consequence)
+		err = c.compile(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		// Remove the additional pop is present
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.leaveBlockValue()
 
 		// Create a jump with a dummy location and store the position to be updated later
 		jumpPos := c.emit(code.OpJump, 9999)
@@ -225,15 +296,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 			// Fill the empty space with a null return value
 			c.emit(code.OpNull)
 		} else {
-			err := c.Compile(node.Alternative)
+			err := c.compile(node.Alternative)
 			if err != nil {
 				return err
 			}
 
-			// Remove any additional pops
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.leaveBlockValue()
 		}
 
 		// Update the location of the jump to after the alternative
@@ -247,9 +315,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Append integer to the constants slice and emit the instruction
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
 	case *ast.ArrayLiteral:
 		for _, el := range node.Elements {
-			err := c.Compile(el)
+			err := c.compile(el)
 			if err != nil {
 				return err
 			}
@@ -258,22 +330,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-
-		for _, k := range keys {
-			err := c.Compile(k)
+		// Keys are compiled in source order so the hash preserves insertion order
+		for _, k := range node.Keys {
+			err := c.compile(k)
 			if err != nil {
 				return err
 			}
 
-			err = c.Compile(node.Pairs[k])
+			err = c.compile(node.Pairs[k])
 			if err != nil {
 				return err
 			}
@@ -293,18 +357,39 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.IndexExpression:
-		err := c.Compile(node.Left)
+		err := c.compile(node.Left)
 		if err != nil {
 			return err
 		}
 
-		err = c.Compile(node.Index)
+		err = c.compile(node.Index)
 		if err != nil {
 			return err
 		}
 
 		c.emit(code.OpIndex)
 
+	case *ast.SliceExpression:
+		err := c.compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		// Omitted bounds are passed as null
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+
+			err := c.compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
+
 	case *ast.FunctionLiteral:
 		c.enterScope()
 
@@ -316,7 +401,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.symbolTable.Define(p.Value)
 		}
 
-		err := c.Compile(node.Body)
+		err := c.compile(node.Body)
 		if err != nil {
 			return err
 		}
@@ -331,6 +416,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		localNames := c.symbolTable.DefinedNames()
+		freeNames := c.symbolTable.FreeNames()
+		sourceMap := c.scopes[c.scopeIndex].sourceMap
 		instructions := c.leaveScope()
 
 		for _, sym := range freeSymbols {
@@ -341,13 +429,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Name:          node.Name,
+			SourceMap:     sourceMap,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
+			Literal:       node,
 		}
 
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 
 	case *ast.ReturnStatement:
-		err := c.Compile(node.ReturnValue)
+		err := c.compile(node.ReturnValue)
 		if err != nil {
 			return err
 		}
@@ -355,13 +448,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpReturnValue)
 
 	case *ast.CallExpression:
-		err := c.Compile(node.Function)
+		err := c.compile(node.Function)
 		if err != nil {
 			return err
 		}
 
 		for _, a := range node.Arguments {
-			err := c.Compile(a)
+			err := c.compile(a)
 			if err != nil {
 				return err
 			}
@@ -377,6 +470,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 // Helper Methods
 // =============================================================================
 
+// errorf records an error and lets compilation go on
+func (c *Compiler) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errors = append(c.errors, Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
 func (c *Compiler) enterScope() {
 	scope := CompilationScope{
 		instructions:        code.Instructions{},
@@ -420,10 +518,26 @@ func (c *Compiler) addInstruction(ins []byte) int {
 	updatedInstructions := append(c.currentInstructions(), ins...)
 
 	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	c.addSourceMapEntry(posNewInstruction)
 
 	return posNewInstruction
 }
 
+// addSourceMapEntry records the current source position for the instruction at offset,
+// unless the previous instruction was compiled from the same position
+func (c *Compiler) addSourceMapEntry(offset int) {
+	if !c.pos.IsValid() {
+		return
+	}
+
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	if n := len(sourceMap); n > 0 && sourceMap[n-1].Pos == c.pos {
+		return
+	}
+
+	c.scopes[c.scopeIndex].sourceMap = append(sourceMap, code.SourceMapEntry{Offset: offset, Pos: c.pos})
+}
+
 func (c *Compiler) addConstant(obj object.Object) int {
 	c.constants = append(c.constants, obj)
 	return len(c.constants) - 1
@@ -454,6 +568,24 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	// Drop source map entries for the removed instruction
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	for len(sourceMap) > 0 && sourceMap[len(sourceMap)-1].Offset >= last.Position {
+		sourceMap = sourceMap[:len(sourceMap)-1]
+	}
+	c.scopes[c.scopeIndex].sourceMap = sourceMap
+}
+
+// leaveBlockValue leaves the value of a block of an if expression on the stack,
+// removing the pop of its final expression or pushing null when the block does
+// not end with one
+func (c *Compiler) leaveBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
@@ -477,6 +609,18 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// isVariable reports whether an expression is the name of a variable rather
+// than of a builtin namespace
+func (c *Compiler) isVariable(node ast.Expression) bool {
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	return ok && symbol.Scope != BuiltinScope
+}
+
 func (c *Compiler) emitSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
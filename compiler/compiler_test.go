@@ -2,6 +2,8 @@ package compiler
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/lukeomalley/monkey_lang/ast"
@@ -9,6 +11,7 @@ import (
 	"github.com/lukeomalley/monkey_lang/lexer"
 	"github.com/lukeomalley/monkey_lang/object"
 	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/token"
 )
 
 type compilerTestCase struct {
@@ -83,6 +86,23 @@ func TestIntegerArithmetic(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1.5 * 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -113,11 +133,11 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "2 < 1",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{2, 1},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
 				code.Make(code.OpPop),
 			},
 		},
@@ -213,6 +233,29 @@ func TestConditionals(t *testing.T) {
 				// 0018
 			},
 		},
+		{
+			// A block ending with a statement has a null value
+			input:             "if (true) { let x = 1; }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -417,6 +460,49 @@ func TestIndexExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2, 3][1:2]",
+			expectedConstants: []interface{}{1, 2, 3, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"monkey"[:3]`,
+			expectedConstants: []interface{}{"monkey", 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"monkey"[1:]`,
+			expectedConstants: []interface{}{"monkey", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestFunctions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -756,6 +842,99 @@ func TestBuiltins(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestNamespacedBuiltins(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Namespace("host").Register("now", 0, func(ctx object.CallContext, args ...object.Object) object.Object {
+		return nil
+	})
+	index := len(builtins.Builtins()) - 1
+
+	program := parse("host.now();")
+	compiler := NewWithBuiltins(builtins)
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := []code.Instructions{
+		code.Make(code.OpGetBuiltin, index),
+		code.Make(code.OpCall, 0),
+		code.Make(code.OpPop),
+	}
+
+	err = testInstructions(expected, compiler.Bytecode().Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	if err := NewWithBuiltins(builtins).Compile(parse("host.later()")); err == nil {
+		t.Errorf("expected compiler error for %q", "host.later()")
+	}
+
+	// A variable shadows the namespace, so its field is accessed instead
+	compiler = NewWithBuiltins(builtins)
+	if err := compiler.Compile(parse("let host = 1; host.now")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected = []code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpGetField, 1),
+		code.Make(code.OpPop),
+	}
+
+	err = testInstructions(expected, compiler.Bytecode().Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	point := &object.StructType{Name: "Point", Fields: []string{"x", "y"}}
+
+	tests := []compilerTestCase{
+		{
+			input:             "struct Point { x, y }; let p = Point(1, 2); p.x = 3; p.y;",
+			expectedConstants: []interface{}{point, 1, 2, 3, "x", "y"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSetField, 4),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetField, 5),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { struct Point { x, y }; Point }",
+			expectedConstants: []interface{}{
+				point,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestClosures(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -935,6 +1114,114 @@ func TestRecursiveFunctions(t *testing.T) {
 // Helper Functions
 // =============================================================================
 
+func TestSourceMap(t *testing.T) {
+	input := "let x = 1;\nx / 2;\nfn() {\n  x\n}"
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+
+	expected := code.SourceMap{
+		{Offset: 0, Pos: pos(1, 9)},  // OpConstant 0
+		{Offset: 3, Pos: pos(1, 1)},  // OpSetGlobal 0
+		{Offset: 6, Pos: pos(2, 1)},  // OpGetGlobal 0
+		{Offset: 9, Pos: pos(2, 5)},  // OpConstant 1
+		{Offset: 12, Pos: pos(2, 3)}, // OpDiv
+		{Offset: 13, Pos: pos(2, 1)}, // OpPop
+		{Offset: 14, Pos: pos(3, 1)}, // OpClosure 2 0, OpPop
+	}
+
+	bytecode := compiler.Bytecode()
+	if !reflect.DeepEqual(bytecode.SourceMap, expected) {
+		t.Errorf("wrong source map.\nwant=%+v\ngot =%+v", expected, bytecode.SourceMap)
+	}
+
+	fn, ok := bytecode.Constants[2].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 2 is not a function. got=%T", bytecode.Constants[2])
+	}
+
+	// The trailing OpPop of the body is replaced with OpReturnValue
+	fnExpected := code.SourceMap{{Offset: 0, Pos: pos(4, 3)}}
+	if !reflect.DeepEqual(fn.SourceMap, fnExpected) {
+		t.Errorf("wrong function source map.\nwant=%+v\ngot =%+v", fnExpected, fn.SourceMap)
+	}
+
+	if got := bytecode.SourceMap.Lookup(13); got != pos(2, 1) {
+		t.Errorf("wrong position for offset 13. got=%s", got)
+	}
+
+	if got := bytecode.SourceMap.Lookup(16); got != pos(3, 1) {
+		t.Errorf("wrong position for offset 16. got=%s", got)
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x", []string{"1:1: undefined variable: x"}},
+		{
+			"let total = 1; let f = fn(count) { totl + cont };\nlenn([1])",
+			[]string{
+				"1:36: undefined variable: totl (did you mean total?)",
+				"1:43: undefined variable: cont (did you mean count?)",
+				"2:1: undefined variable: lenn (did you mean len?)",
+			},
+		},
+		{"math.ab(1); mth.abs(1)", []string{
+			"1:1: undefined variable: math.ab (did you mean math.abs?)",
+			"1:13: undefined variable: mth.abs (did you mean math.abs?)",
+		}},
+		{"let p = 1; p.x = y; q.x", []string{
+			"1:18: undefined variable: y",
+			"1:21: undefined variable: q.x",
+		}},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		errs, ok := err.(ErrorList)
+		if !ok {
+			t.Errorf("expected an ErrorList for %q, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+
+		got := strings.Split(errs.Error(), "\n")
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		expected   string
+	}{
+		{"lenght", []string{"len", "length", "last"}, "length"},
+		{"x", []string{"y", "xs"}, ""},
+		{"abcdef", []string{"abxxef", "abcxef"}, "abcxef"},
+		{"push", []string{"puts", "pusx"}, "pusx"},
+		{"cuont", []string{"count", "cout"}, "count"},
+		{"ab", []string{"ba"}, ""},
+	}
+
+	for _, tt := range tests {
+		got, _ := closestName(tt.name, tt.candidates)
+		if got != tt.expected {
+			t.Errorf("closestName(%q) wrong. want=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -1005,12 +1292,22 @@ func testConstants(t *testing.T, expected []interface{}, actual []object.Object)
 			if err != nil {
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s", i, err)
 			}
+		case float64:
+			f, ok := actual[i].(*object.Float)
+			if !ok || f.Value != constant {
+				return fmt.Errorf("constant %d - not Float %v. got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
 		case string:
 			err := testStringObject(constant, actual[i])
 			if err != nil {
 				return fmt.Errorf("constant %d - testStringObject failed: %s", i, err)
 			}
 
+		case *object.StructType:
+			if actual[i].Inspect() != constant.Inspect() {
+				return fmt.Errorf("constant %d - wrong struct type. want=%q, got=%q", i, constant.Inspect(), actual[i].Inspect())
+			}
+
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 			if !ok {
@@ -0,0 +1,212 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/code"
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+// Disassemble returns a listing of the main program followed by every function
+// it creates, directly or through other functions. Constant operands are shown
+// with their values, global, local, free and builtin operands with the names of
+// the symbols and jump operands with labels.
+func Disassemble(bytecode *Bytecode) string {
+	d := &disassembler{bytecode: bytecode, seen: make(map[int]bool)}
+
+	d.writeHeader("main")
+	d.writeInstructions(bytecode.Instructions, nil)
+
+	// Functions are listed in the order in which they are first created
+	for len(d.queue) > 0 {
+		index := d.queue[0]
+		d.queue = d.queue[1:]
+
+		fn := bytecode.Constants[index].(*object.CompiledFunction)
+		d.out.WriteString("\n")
+		d.writeHeader(fmt.Sprintf("%s (constant %d) params=%d locals=%d free=%d",
+			functionName(fn), index, fn.NumParameters, fn.NumLocals, len(fn.FreeNames)))
+		d.writeInstructions(fn.Instructions, fn)
+	}
+
+	return d.out.String()
+}
+
+type disassembler struct {
+	bytecode *Bytecode
+	out      bytes.Buffer
+	queue    []int        // constant indexes of the functions still to be listed
+	seen     map[int]bool // constant indexes of the functions already queued
+}
+
+func (d *disassembler) writeHeader(title string) {
+	fmt.Fprintf(&d.out, "== %s ==\n", title)
+}
+
+// writeInstructions lists the instructions of the main program, or of fn when it is not nil
+func (d *disassembler) writeInstructions(ins code.Instructions, fn *object.CompiledFunction) {
+	labels := jumpLabels(ins)
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&d.out, "%04d Error: %s\n", i, err)
+			i++
+			continue
+		}
+
+		if label, ok := labels[i]; ok {
+			fmt.Fprintf(&d.out, "%s:\n", label)
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		text := def.Name
+		for _, operand := range operands {
+			text += " " + strconv.Itoa(operand)
+		}
+
+		if comment := d.annotate(code.Opcode(ins[i]), operands, labels, fn); comment != "" {
+			fmt.Fprintf(&d.out, "%04d %-24s ; %s\n", i, text, comment)
+		} else {
+			fmt.Fprintf(&d.out, "%04d %s\n", i, text)
+		}
+
+		i += 1 + read
+	}
+
+	// A jump past the last instruction leaves the function
+	if label, ok := labels[len(ins)]; ok {
+		fmt.Fprintf(&d.out, "%s:\n", label)
+	}
+}
+
+// annotate describes the operands of an instruction, queueing any function it creates
+func (d *disassembler) annotate(op code.Opcode, operands []int, labels map[int]string, fn *object.CompiledFunction) string {
+	switch op {
+	case code.OpConstant:
+		return d.constant(operands[0])
+
+	case code.OpClosure:
+		if _, ok := d.function(operands[0]); ok && !d.seen[operands[0]] {
+			d.seen[operands[0]] = true
+			d.queue = append(d.queue, operands[0])
+		}
+		return fmt.Sprintf("%s, %d free", d.constant(operands[0]), operands[1])
+
+	case code.OpJump, code.OpJumpNotTruthy:
+		return labels[operands[0]]
+
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return "global " + symbolName(d.bytecode.GlobalNames, operands[0])
+
+	case code.OpGetBuiltin:
+		return "builtin " + symbolName(d.bytecode.BuiltinNames, operands[0])
+
+	case code.OpGetLocal, code.OpSetLocal:
+		if fn == nil {
+			return ""
+		}
+		return "local " + symbolName(fn.LocalNames, operands[0])
+
+	case code.OpGetFree:
+		if fn == nil {
+			return ""
+		}
+		return "free " + symbolName(fn.FreeNames, operands[0])
+
+	case code.OpGetField, code.OpSetField:
+		if index := operands[0]; index < len(d.bytecode.Constants) {
+			if name, ok := d.bytecode.Constants[index].(*object.String); ok {
+				return "field " + name.Value
+			}
+		}
+		return d.constant(operands[0])
+
+	case code.OpCurrentClosure:
+		if fn == nil {
+			return ""
+		}
+		return functionName(fn)
+
+	default:
+		return ""
+	}
+}
+
+// constant describes the constant at index, quoting strings so they stand out
+func (d *disassembler) constant(index int) string {
+	if index >= len(d.bytecode.Constants) {
+		return fmt.Sprintf("<invalid constant %d>", index)
+	}
+
+	switch obj := d.bytecode.Constants[index].(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.CompiledFunction:
+		return functionName(obj)
+	default:
+		return obj.Inspect()
+	}
+}
+
+func (d *disassembler) function(index int) (*object.CompiledFunction, bool) {
+	if index >= len(d.bytecode.Constants) {
+		return nil, false
+	}
+
+	fn, ok := d.bytecode.Constants[index].(*object.CompiledFunction)
+	return fn, ok
+}
+
+// jumpLabels names the targets of the jumps in ins L1, L2, ... in offset order
+func jumpLabels(ins code.Instructions) map[int]string {
+	targets := []int{}
+	seen := make(map[int]bool)
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			i++
+			continue
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		op := code.Opcode(ins[i])
+		if (op == code.OpJump || op == code.OpJumpNotTruthy) && !seen[operands[0]] {
+			seen[operands[0]] = true
+			targets = append(targets, operands[0])
+		}
+
+		i += 1 + read
+	}
+
+	sort.Ints(targets)
+
+	labels := make(map[int]string, len(targets))
+	for i, target := range targets {
+		labels[target] = fmt.Sprintf("L%d", i+1)
+	}
+
+	return labels
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "fn <anonymous>"
+	}
+
+	return "fn " + fn.Name
+}
+
+func symbolName(names []string, index int) string {
+	if index < len(names) && strings.TrimSpace(names[index]) != "" {
+		return names[index]
+	}
+
+	return "#" + strconv.Itoa(index)
+}
@@ -0,0 +1,60 @@
+package compiler
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	input := `
+let greeting = "hi";
+let adder = fn(a) {
+	let b = a;
+	fn(c) { if (c > b) { len(greeting) } else { adder(c) } }
+};
+adder(1)(2);
+`
+
+	expected := `== main ==
+0000 OpConstant 0             ; "hi"
+0003 OpSetGlobal 0            ; global greeting
+0006 OpClosure 2 0            ; fn adder, 0 free
+0010 OpSetGlobal 1            ; global adder
+0013 OpGetGlobal 1            ; global adder
+0016 OpConstant 3             ; 1
+0019 OpCall 1
+0021 OpConstant 4             ; 2
+0024 OpCall 1
+0026 OpPop
+
+== fn adder (constant 2) params=1 locals=2 free=0 ==
+0000 OpGetLocal 0             ; local a
+0002 OpSetLocal 1             ; local b
+0004 OpGetLocal 1             ; local b
+0006 OpCurrentClosure         ; fn adder
+0007 OpClosure 1 2            ; fn <anonymous>, 2 free
+0011 OpReturnValue
+
+== fn <anonymous> (constant 1) params=1 locals=1 free=2 ==
+0000 OpGetLocal 0             ; local c
+0002 OpGetFree 0              ; free b
+0004 OpGreaterThan
+0005 OpJumpNotTruthy 19       ; L1
+0008 OpGetBuiltin 0           ; builtin len
+0011 OpGetGlobal 0            ; global greeting
+0014 OpCall 1
+0016 OpJump 25                ; L2
+L1:
+0019 OpGetFree 1              ; free adder
+0021 OpGetLocal 0             ; local c
+0023 OpCall 1
+L2:
+0025 OpReturnValue
+`
+
+	comp := New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	if got := Disassemble(comp.Bytecode()); got != expected {
+		t.Errorf("wrong disassembly.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
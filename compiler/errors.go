@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// Error is a compiler error at a position in the source
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// ErrorList is every error found while compiling a program, ordered by position
+type ErrorList []Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Sort orders the errors by their position in the source
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Pos, l[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+}
+
+// undefined returns the error for a name that does not resolve, suggesting the
+// closest visible name when one is similar enough
+func undefined(pos token.Position, name string, table *SymbolTable) Error {
+	msg := fmt.Sprintf("undefined variable: %s", name)
+	if suggestion, ok := closestName(name, table.VisibleNames()); ok {
+		msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+	}
+
+	return Error{Pos: pos, Message: msg}
+}
+
+// closestName returns the candidate with the smallest edit distance to name,
+// allowing one edit for every three characters of the name
+func closestName(name string, candidates []string) (string, bool) {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		d := editDistance(name, candidate)
+		if d < bestDistance || d == bestDistance && best != "" && candidate < best {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best, best != ""
+}
+
+// editDistance returns the optimal string alignment distance between two
+// strings: the Levenshtein distance, where swapping two adjacent characters
+// also counts as a single edit
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
@@ -1,5 +1,7 @@
 package compiler
 
+import "sort"
+
 // SymbolScope stores the scope of the symbol
 type SymbolScope string
 
@@ -25,6 +27,8 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+	definedNames   []string // names of the defined symbols, indexed by symbol index
+	builtinNames   []string // names of the builtin symbols, indexed by symbol index
 }
 
 // NewSymbolTable constructs a symbol table
@@ -52,6 +56,7 @@ func (s *SymbolTable) Define(name string) Symbol {
 
 	s.store[name] = symbol
 	s.numDefinitions++
+	s.definedNames = append(s.definedNames, name)
 	return symbol
 }
 
@@ -79,6 +84,12 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
 	s.store[name] = symbol
+
+	for len(s.builtinNames) <= index {
+		s.builtinNames = append(s.builtinNames, "")
+	}
+	s.builtinNames[index] = name
+
 	return symbol
 }
 
@@ -97,3 +108,46 @@ func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	s.store[name] = symbol
 	return symbol
 }
+
+// DefinedNames returns the names of the global or local symbols defined in the
+// table, indexed by symbol index. Names that were shadowed by a later definition
+// are kept, so every index has a name.
+func (s *SymbolTable) DefinedNames() []string {
+	names := make([]string, len(s.definedNames))
+	copy(names, s.definedNames)
+	return names
+}
+
+// BuiltinNames returns the names of the builtin symbols, indexed by symbol index
+func (s *SymbolTable) BuiltinNames() []string {
+	names := make([]string, len(s.builtinNames))
+	copy(names, s.builtinNames)
+	return names
+}
+
+// FreeNames returns the names of the free symbols captured by the table
+func (s *SymbolTable) FreeNames() []string {
+	names := make([]string, len(s.FreeSymbols))
+	for i, sym := range s.FreeSymbols {
+		names[i] = sym.Name
+	}
+	return names
+}
+
+// VisibleNames returns the sorted names that resolve in the table, including
+// those of the enclosing tables and the builtins
+func (s *SymbolTable) VisibleNames() []string {
+	seen := make(map[string]bool)
+	for table := s; table != nil; table = table.Outer {
+		for name := range table.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,6 +1,9 @@
 package compiler
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -340,3 +343,35 @@ func TestShadowingFunctionName(t *testing.T) {
 		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
 	}
 }
+
+func TestSymbolNames(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(1, "len")
+	global.DefineBuiltin(0, "puts")
+	global.Define("a")
+	global.Define("b")
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+	nested := NewEnclosedSymbolTable(local)
+	nested.Resolve("c")
+	nested.Resolve("a")
+
+	tests := []struct {
+		got      []string
+		expected []string
+	}{
+		{global.DefinedNames(), []string{"a", "b", "a"}},
+		{global.BuiltinNames(), []string{"puts", "len"}},
+		{local.DefinedNames(), []string{"c"}},
+		{nested.FreeNames(), []string{"c"}},
+		{nested.VisibleNames(), []string{"a", "b", "c", "len", "puts"}},
+	}
+
+	for i, tt := range tests {
+		if strings.Join(tt.got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("tests[%d] wrong names. want=%v, got=%v", i, tt.expected, tt.got)
+		}
+	}
+}
@@ -0,0 +1,649 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/lukeomalley/monkey_lang/code"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/debugger"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/vm"
+)
+
+// threadID identifies the only thread of a Monkey program
+const threadID = 1
+
+var errNotPaused = errors.New("the program is not paused")
+
+// Server implements the Debug Adapter Protocol for a single Monkey program
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	writeMu sync.Mutex // guards out and seq
+	seq     int
+
+	linesStartAt1   bool
+	columnsStartAt1 bool
+
+	program     string
+	bytecode    *compiler.Bytecode
+	codeLines   map[int]bool // lines with instructions, nil until launched
+	debugger    *debugger.Debugger
+	breakpoints []int
+	stopOnEntry bool
+	noDebug     bool
+
+	pausedMu sync.Mutex // guards paused
+	paused   bool
+	commands chan *command // run by the vm goroutine while the program is paused
+	quit     chan struct{} // closed to resume a paused program that is being stopped
+	done     chan struct{} // closed once the program has exited
+
+	// Variable references handed out while paused, only used on the vm goroutine
+	refs []func() []variable
+}
+
+// command runs on the vm goroutine while the program is paused and reports
+// whether the program should resume
+type command struct {
+	fn   func(d *debugger.Debugger) bool
+	done chan struct{}
+}
+
+// NewServer constructs a server reading requests from in and writing responses
+// and events to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:              bufio.NewReader(in),
+		out:             out,
+		linesStartAt1:   true,
+		columnsStartAt1: true,
+		commands:        make(chan *command),
+		quit:            make(chan struct{}),
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the input
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Serve()
+}
+
+// Serve handles requests until the client disconnects or closes the input
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			s.stopProgram()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Type != "request" {
+			continue
+		}
+
+		if msg.Command == "disconnect" {
+			s.stopProgram()
+			s.respond(msg, nil)
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			s.respondError(msg, err)
+		}
+	}
+}
+
+// handle answers a single request, returning an error to report a failure
+func (s *Server) handle(req *Message) error {
+	switch req.Command {
+	case "initialize":
+		var args initializeArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		if args.LinesStartAt1 != nil {
+			s.linesStartAt1 = *args.LinesStartAt1
+		}
+		if args.ColumnsStartAt1 != nil {
+			s.columnsStartAt1 = *args.ColumnsStartAt1
+		}
+
+		s.respond(req, capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsTerminateRequest:         true,
+			SupportsEvaluateForHovers:        true,
+		})
+		s.sendEvent("initialized", nil)
+
+	case "launch":
+		var args launchArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		if err := s.launch(args); err != nil {
+			return err
+		}
+		s.respond(req, nil)
+
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		s.respond(req, breakpointsBody{Breakpoints: s.setBreakpoints(args.Breakpoints)})
+
+	case "configurationDone":
+		if s.debugger == nil {
+			return fmt.Errorf("no program has been launched")
+		}
+
+		s.respond(req, nil)
+		s.start()
+
+	case "threads":
+		s.respond(req, threadsBody{Threads: []thread{{ID: threadID, Name: "main"}}})
+
+	case "stackTrace":
+		var body stackTraceBody
+		err := s.whilePaused(func(d *debugger.Debugger) bool {
+			body = s.stackTrace(d)
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		s.respond(req, body)
+
+	case "scopes":
+		var args frameArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		var body scopesBody
+		err := s.whilePaused(func(d *debugger.Debugger) bool {
+			body = s.scopes(d, args.FrameID)
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		s.respond(req, body)
+
+	case "variables":
+		var args variablesArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		var body variablesBody
+		err := s.whilePaused(func(d *debugger.Debugger) bool {
+			body.Variables = s.variables(args.VariablesReference)
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		s.respond(req, body)
+
+	case "evaluate":
+		var args evaluateArguments
+		if err := decodeArguments(req, &args); err != nil {
+			return err
+		}
+
+		var body evaluateBody
+		var lookupErr error
+		err := s.whilePaused(func(d *debugger.Debugger) bool {
+			frame := 0
+			if args.FrameID != nil {
+				frame = *args.FrameID
+			}
+
+			value, ok := d.Lookup(frame, args.Expression)
+			if !ok {
+				lookupErr = fmt.Errorf("no variable named %s", args.Expression)
+				return false
+			}
+
+			v := s.newVariable(args.Expression, value)
+			body = evaluateBody{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		if lookupErr != nil {
+			return lookupErr
+		}
+		s.respond(req, body)
+
+	case "continue", "next", "stepIn", "stepOut":
+		// The response must precede the events caused by resuming
+		s.respond(req, continueBody{AllThreadsContinued: true})
+
+		return s.whilePaused(func(d *debugger.Debugger) bool {
+			switch req.Command {
+			case "continue":
+				d.Continue()
+			case "next":
+				d.StepOver()
+			case "stepIn":
+				d.StepInto()
+			case "stepOut":
+				d.StepOut()
+			}
+			return true
+		})
+
+	case "pause":
+		if s.debugger == nil {
+			return fmt.Errorf("no program has been launched")
+		}
+
+		s.debugger.Pause()
+		s.respond(req, nil)
+
+	case "terminate":
+		s.stopProgram()
+		s.respond(req, nil)
+
+	default:
+		return fmt.Errorf("unsupported command %q", req.Command)
+	}
+
+	return nil
+}
+
+// launch compiles the program, which starts running once the client is done
+// configuring breakpoints
+func (s *Server) launch(args launchArguments) error {
+	if s.debugger != nil {
+		return fmt.Errorf("a program has already been launched")
+	}
+
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	bytecode, err := compile(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %s", args.Program, err)
+	}
+
+	s.program = args.Program
+	s.bytecode = bytecode
+	s.codeLines = codeLines(bytecode)
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+
+	machine := vm.New(bytecode)
+	machine.SetOutput(&outputWriter{server: s, category: "stdout"}, &outputWriter{server: s, category: "stderr"})
+
+	s.debugger = debugger.New(machine)
+	s.debugger.Stopped = s.stopped
+	s.applyBreakpoints()
+
+	return nil
+}
+
+// start runs the launched program on its own goroutine
+func (s *Server) start() {
+	if s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+
+	if !s.stopOnEntry || s.noDebug {
+		s.debugger.Continue()
+	}
+
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		err := s.debugger.Run()
+		if err != nil && err != debugger.ErrStopped {
+			s.sendEvent("output", outputBody{Category: "stderr", Output: err.Error() + "\n"})
+			exitCode = 1
+		}
+
+		s.sendEvent("exited", exitedBody{ExitCode: exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+}
+
+// stopped is called on the vm goroutine whenever the program pauses. It runs
+// the commands sent by the request handlers until one of them resumes it.
+func (s *Server) stopped(d *debugger.Debugger, reason debugger.StopReason) error {
+	s.refs = nil
+	s.setPaused(true)
+
+	s.sendEvent("stopped", stoppedBody{Reason: string(reason), ThreadID: threadID, AllThreadsStopped: true})
+
+	for {
+		select {
+		case cmd := <-s.commands:
+			resume := cmd.fn(d)
+			if resume {
+				s.setPaused(false)
+			}
+			close(cmd.done)
+
+			if resume {
+				return nil
+			}
+
+		case <-s.quit:
+			s.setPaused(false)
+			return nil
+		}
+	}
+}
+
+// whilePaused runs fn on the vm goroutine, which must be paused
+func (s *Server) whilePaused(fn func(d *debugger.Debugger) bool) error {
+	s.pausedMu.Lock()
+	paused := s.paused
+	s.pausedMu.Unlock()
+
+	if !paused {
+		return errNotPaused
+	}
+
+	cmd := &command{fn: fn, done: make(chan struct{})}
+	s.commands <- cmd
+	<-cmd.done
+
+	return nil
+}
+
+func (s *Server) setPaused(paused bool) {
+	s.pausedMu.Lock()
+	defer s.pausedMu.Unlock()
+
+	s.paused = paused
+}
+
+// stopProgram stops a running or paused program and waits for it to exit
+func (s *Server) stopProgram() {
+	if s.debugger == nil || s.done == nil {
+		return
+	}
+
+	// The program may pause before it notices the stop, so quit is closed
+	// rather than sending a command that would only resume a paused program
+	s.debugger.Stop()
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+	<-s.done
+}
+
+func (s *Server) setBreakpoints(requested []sourceBreakpoint) []breakpoint {
+	s.breakpoints = s.breakpoints[:0]
+	result := make([]breakpoint, len(requested))
+	for i, bp := range requested {
+		line := s.fromClientLine(bp.Line)
+		s.breakpoints = append(s.breakpoints, line)
+
+		result[i] = breakpoint{Verified: true, Line: bp.Line}
+		if s.codeLines != nil && !s.codeLines[line] {
+			result[i] = breakpoint{Verified: false, Line: bp.Line, Message: "no code on this line"}
+		}
+	}
+
+	s.applyBreakpoints()
+	return result
+}
+
+func (s *Server) applyBreakpoints() {
+	if s.debugger == nil || s.noDebug {
+		return
+	}
+
+	s.debugger.ClearBreakpoints()
+	for _, line := range s.breakpoints {
+		s.debugger.SetBreakpoint(line)
+	}
+}
+
+func (s *Server) stackTrace(d *debugger.Debugger) stackTraceBody {
+	frames := d.VM().Frames()
+
+	body := stackTraceBody{TotalFrames: len(frames)}
+	for i, frame := range frames {
+		body.StackFrames = append(body.StackFrames, stackFrame{
+			ID:     i,
+			Name:   frame.Name,
+			Source: &source{Name: s.program, Path: s.program},
+			Line:   s.toClientLine(frame.Pos.Line),
+			Column: s.toClientColumn(frame.Pos.Column),
+		})
+	}
+
+	return body
+}
+
+func (s *Server) scopes(d *debugger.Debugger, frame int) scopesBody {
+	machine := d.VM()
+
+	scopes := []scope{}
+	if locals := machine.Locals(frame); len(locals) > 0 {
+		scopes = append(scopes, scope{Name: "Locals", VariablesReference: s.newReference(func() []variable {
+			return s.newVariables(machine.Locals(frame))
+		})})
+	}
+
+	if free := machine.FreeVariables(frame); len(free) > 0 {
+		scopes = append(scopes, scope{Name: "Closure", VariablesReference: s.newReference(func() []variable {
+			return s.newVariables(machine.FreeVariables(frame))
+		})})
+	}
+
+	scopes = append(scopes, scope{Name: "Globals", VariablesReference: s.newReference(func() []variable {
+		return s.newVariables(machine.Globals())
+	})})
+
+	return scopesBody{Scopes: scopes}
+}
+
+func (s *Server) variables(reference int) []variable {
+	if reference < 1 || reference > len(s.refs) {
+		return []variable{}
+	}
+
+	return s.refs[reference-1]()
+}
+
+// newReference registers the children of a scope or value, returning the
+// reference the client uses to request them
+func (s *Server) newReference(children func() []variable) int {
+	s.refs = append(s.refs, children)
+	return len(s.refs)
+}
+
+func (s *Server) newVariables(vars []vm.Variable) []variable {
+	result := make([]variable, len(vars))
+	for i, v := range vars {
+		result[i] = s.newVariable(v.Name, v.Value)
+	}
+
+	return result
+}
+
+// newVariable describes a value, letting the client expand arrays, hashes and
+// structs
+func (s *Server) newVariable(name string, value object.Object) variable {
+	if value == nil {
+		return variable{Name: name, Value: "<unset>"}
+	}
+
+	v := variable{Name: name, Value: value.Inspect(), Type: string(object.TypeName(value))}
+
+	switch value := value.(type) {
+	case *object.String:
+		v.Value = strconv.Quote(value.Value)
+
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			v.VariablesReference = s.newReference(func() []variable {
+				children := make([]variable, len(value.Elements))
+				for i, el := range value.Elements {
+					children[i] = s.newVariable(fmt.Sprintf("[%d]", i), el)
+				}
+				return children
+			})
+		}
+
+	case *object.Hash:
+		if value.Len() > 0 {
+			v.VariablesReference = s.newReference(func() []variable {
+				children := make([]variable, value.Len())
+				for i, pair := range value.Pairs() {
+					children[i] = s.newVariable(s.newVariable("", pair.Key).Value, pair.Value)
+				}
+				return children
+			})
+		}
+
+	case *object.Struct:
+		if len(value.Fields) > 0 {
+			v.VariablesReference = s.newReference(func() []variable {
+				children := make([]variable, len(value.Fields))
+				for i, field := range value.Fields {
+					children[i] = s.newVariable(value.Def.Fields[i], field)
+				}
+				return children
+			})
+		}
+	}
+
+	return v
+}
+
+func (s *Server) respond(req *Message, body interface{}) {
+	s.write(&response{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *Server) respondError(req *Message, err error) {
+	s.write(&response{Type: "response", RequestSeq: req.Seq, Success: false, Command: req.Command, Message: err.Error()})
+}
+
+func (s *Server) sendEvent(name string, body interface{}) {
+	s.write(&event{Type: "event", Event: name, Body: body})
+}
+
+// write assigns the next sequence number to a response or event and writes it
+func (s *Server) write(msg interface{}) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+
+	WriteMessage(s.out, msg)
+}
+
+func (s *Server) toClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line - 1
+}
+
+func (s *Server) fromClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line + 1
+}
+
+func (s *Server) toClientColumn(column int) int {
+	if s.columnsStartAt1 {
+		return column
+	}
+	return column - 1
+}
+
+// outputWriter forwards the output of the program to the client as output events
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.server.sendEvent("output", outputBody{Category: w.category, Output: string(p)})
+	return len(p), nil
+}
+
+func decodeArguments(req *Message, v interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(req.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments for %s: %s", req.Command, err)
+	}
+
+	return nil
+}
+
+func compile(src string) (*compiler.Bytecode, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser error: %s", p.Errors()[0])
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	return comp.Bytecode(), nil
+}
+
+// codeLines returns the lines that have instructions in the main program or any function
+func codeLines(bytecode *compiler.Bytecode) map[int]bool {
+	lines := make(map[int]bool)
+
+	add := func(sm code.SourceMap) {
+		for _, entry := range sm {
+			lines[entry.Pos.Line] = true
+		}
+	}
+
+	add(bytecode.SourceMap)
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			add(fn.SourceMap)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,255 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+puts(x);`
+
+type client struct {
+	t        *testing.T
+	in       io.Writer
+	messages chan *Message
+	served   chan error
+	seq      int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, in: clientOut, messages: make(chan *Message, 100), served: make(chan error, 1)}
+
+	go func() {
+		c.served <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			msg, err := ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) send(command string, arguments interface{}) {
+	c.t.Helper()
+
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments}
+	if err := WriteMessage(c.in, req); err != nil {
+		c.t.Fatalf("could not send %s: %s", command, err)
+	}
+}
+
+// expect skips messages until the response to the command or the event arrives
+func (c *client) expect(kind, name string, body interface{}) *Message {
+	c.t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for %s %s", kind, name)
+			}
+
+			if msg.Type != kind || (msg.Command != name && msg.Event != name) {
+				continue
+			}
+
+			if kind == "response" && !msg.Success {
+				c.t.Fatalf("%s failed: %s", name, msg.Message)
+			}
+
+			if body != nil {
+				if err := json.Unmarshal(msg.Body, body); err != nil {
+					c.t.Fatalf("invalid %s body: %s", name, err)
+				}
+			}
+			return msg
+
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s %s", kind, name)
+		}
+	}
+}
+
+func (c *client) launch(args map[string]interface{}) {
+	c.t.Helper()
+
+	path := filepath.Join(c.t.TempDir(), "program.mk")
+	if err := os.WriteFile(path, []byte(program), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+	args["program"] = path
+
+	c.send("initialize", map[string]interface{}{"adapterID": "monkey"})
+	c.expect("response", "initialize", nil)
+	c.expect("event", "initialized", nil)
+
+	c.send("launch", args)
+	c.expect("response", "launch", nil)
+}
+
+func TestDebugSession(t *testing.T) {
+	c := newClient(t)
+	c.launch(map[string]interface{}{})
+
+	var bps breakpointsBody
+	c.send("setBreakpoints", map[string]interface{}{"breakpoints": []map[string]int{{"line": 2}, {"line": 4}}})
+	c.expect("response", "setBreakpoints", &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[1].Verified {
+		t.Errorf("wrong breakpoints. got=%+v", bps.Breakpoints)
+	}
+
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone", nil)
+
+	var stopped stoppedBody
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("wrong stop reason. want=breakpoint, got=%s", stopped.Reason)
+	}
+
+	var trace stackTraceBody
+	c.send("stackTrace", map[string]int{"threadId": threadID})
+	c.expect("response", "stackTrace", &trace)
+	if trace.TotalFrames != 2 || trace.StackFrames[0].Name != "add" || trace.StackFrames[0].Line != 2 {
+		t.Fatalf("wrong stack trace. got=%+v", trace)
+	}
+
+	var scopes scopesBody
+	c.send("scopes", map[string]int{"frameId": 0})
+	c.expect("response", "scopes", &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes. got=%+v", scopes.Scopes)
+	}
+
+	var vars variablesBody
+	c.send("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference})
+	c.expect("response", "variables", &vars)
+
+	got := []string{}
+	for _, v := range vars.Variables {
+		got = append(got, v.Name+"="+v.Value)
+	}
+	if strings.Join(got, " ") != "a=1 b=2 sum=<unset>" {
+		t.Errorf("wrong locals. got=%v", got)
+	}
+
+	var result evaluateBody
+	c.send("evaluate", map[string]interface{}{"expression": "b", "frameId": 0})
+	c.expect("response", "evaluate", &result)
+	if result.Result != "2" || result.Type != "INTEGER" {
+		t.Errorf("wrong evaluation. got=%+v", result)
+	}
+
+	c.send("next", map[string]int{"threadId": threadID})
+	c.expect("response", "next", nil)
+	c.expect("event", "stopped", &stopped)
+
+	c.send("stackTrace", map[string]int{"threadId": threadID})
+	c.expect("response", "stackTrace", &trace)
+	if stopped.Reason != "step" || trace.StackFrames[0].Line != 3 {
+		t.Errorf("wrong step. reason=%s, line=%d", stopped.Reason, trace.StackFrames[0].Line)
+	}
+
+	c.send("continue", map[string]int{"threadId": threadID})
+	c.expect("response", "continue", nil)
+
+	var output outputBody
+	c.expect("event", "output", &output)
+	if output.Category != "stdout" || output.Output != "3\n" {
+		t.Errorf("wrong output. got=%+v", output)
+	}
+
+	var exited exitedBody
+	c.expect("event", "exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code. want=0, got=%d", exited.ExitCode)
+	}
+	c.expect("event", "terminated", nil)
+
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDisconnectWhilePaused(t *testing.T) {
+	c := newClient(t)
+	c.launch(map[string]interface{}{"stopOnEntry": true})
+
+	c.send("configurationDone", nil)
+
+	var stopped stoppedBody
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != "entry" {
+		t.Errorf("wrong stop reason. want=entry, got=%s", stopped.Reason)
+	}
+
+	c.send("disconnect", nil)
+	c.expect("event", "terminated", nil)
+	c.expect("response", "disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	c := newClient(t)
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"stackTrace", "the program is not paused"},
+		{"configurationDone", "no program has been launched"},
+		{"restart", `unsupported command "restart"`},
+	}
+
+	for _, tt := range tests {
+		c.send(tt.command, nil)
+
+		timeout := time.After(5 * time.Second)
+		var msg *Message
+		for msg == nil {
+			select {
+			case m := <-c.messages:
+				if m.Type == "response" && m.Command == tt.command {
+					msg = m
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", tt.command)
+			}
+		}
+
+		if msg.Success || msg.Message != tt.expected {
+			t.Errorf("wrong response to %s. want error %q, got success=%t message=%q", tt.command, tt.expected, msg.Success, msg.Message)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/vm"
+)
+
+// PROMPT is the prompt shown while the program is paused
+const PROMPT = "(debug) "
+
+const help = `Commands:
+	break <line>, b     pause whenever the line is reached
+	delete <line>, d    remove the breakpoint on the line
+	breakpoints         list the breakpoints
+	continue, c         resume until the next breakpoint
+	step, s             step to the next line, entering calls
+	next, n             step to the next line of the current function
+	out, o              resume until the current function returns
+	backtrace, bt       show the active calls
+	frame <n>, f        select the frame used by locals and print
+	locals              show the locals and free variables of the frame
+	globals             show the globals
+	print <name>, p     show the value of a variable
+	list                show the source around the current line
+	quit, q             stop the program
+`
+
+// Start runs the program compiled from source under an interactive debugger,
+// reading commands from in and writing to out. The program pauses on its first
+// line. It returns the runtime error of the program, or nil when the program
+// completes or the user quits.
+func Start(machine *vm.VM, source string, in io.Reader, out io.Writer) error {
+	c := &cli{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+		source:  strings.Split(source, "\n"),
+	}
+
+	d := New(machine)
+	d.Stopped = c.stopped
+
+	err := d.Run()
+	if c.quit {
+		return nil
+	}
+
+	if err == nil {
+		fmt.Fprintln(out, "Program finished")
+	}
+
+	return err
+}
+
+type cli struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+	source  []string
+	frame   int  // selected frame, 0 is the innermost
+	quit    bool // set when the user stopped the program
+}
+
+var errQuit = fmt.Errorf("stopped by the debugger")
+
+// stopped shows where the program paused and reads commands until one resumes it
+func (c *cli) stopped(d *Debugger, reason StopReason) error {
+	c.frame = 0
+
+	frames := d.VM().Frames()
+	pos := frames[0].Pos
+	fmt.Fprintf(c.out, "Stopped at %s in %s (%s)\n", pos, frames[0].Name, reason)
+	c.printLine(pos.Line, true)
+
+	for {
+		fmt.Fprint(c.out, PROMPT)
+		if !c.scanner.Scan() {
+			c.quit = true
+			return errQuit
+		}
+
+		fields := strings.Fields(c.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "continue", "c":
+			d.Continue()
+			return nil
+
+		case "step", "s":
+			d.StepInto()
+			return nil
+
+		case "next", "n":
+			d.StepOver()
+			return nil
+
+		case "out", "o":
+			d.StepOut()
+			return nil
+
+		case "quit", "q":
+			c.quit = true
+			return errQuit
+
+		case "break", "b":
+			if line, ok := c.lineArg(args); ok {
+				d.SetBreakpoint(line)
+				fmt.Fprintf(c.out, "Breakpoint set at line %d\n", line)
+			}
+
+		case "delete", "d":
+			if line, ok := c.lineArg(args); ok {
+				if d.ClearBreakpoint(line) {
+					fmt.Fprintf(c.out, "Breakpoint removed from line %d\n", line)
+				} else {
+					fmt.Fprintf(c.out, "No breakpoint at line %d\n", line)
+				}
+			}
+
+		case "breakpoints":
+			for _, line := range d.Breakpoints() {
+				c.printLine(line, false)
+			}
+
+		case "backtrace", "bt":
+			for i, frame := range d.VM().Frames() {
+				marker := " "
+				if i == c.frame {
+					marker = "*"
+				}
+				fmt.Fprintf(c.out, "%s #%d %s at %s\n", marker, i, frame.Name, frame.Pos)
+			}
+
+		case "frame", "f":
+			n, err := c.intArg(args)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+				continue
+			}
+
+			frames := d.VM().Frames()
+			if n < 0 || n >= len(frames) {
+				fmt.Fprintf(c.out, "No frame #%d\n", n)
+				continue
+			}
+
+			c.frame = n
+			fmt.Fprintf(c.out, "#%d %s at %s\n", n, frames[n].Name, frames[n].Pos)
+			c.printLine(frames[n].Pos.Line, true)
+
+		case "locals":
+			c.printVariables(d.VM().Locals(c.frame))
+			c.printVariables(d.VM().FreeVariables(c.frame))
+
+		case "globals":
+			c.printVariables(d.VM().Globals())
+
+		case "print", "p":
+			if len(args) != 1 {
+				fmt.Fprintln(c.out, "usage: print <name>")
+				continue
+			}
+
+			value, ok := d.Lookup(c.frame, args[0])
+			if !ok {
+				fmt.Fprintf(c.out, "No variable named %s\n", args[0])
+				continue
+			}
+			fmt.Fprintf(c.out, "%s = %s\n", args[0], value.Inspect())
+
+		case "list":
+			line := d.VM().Frames()[c.frame].Pos.Line
+			for l := line - 2; l <= line+2; l++ {
+				c.printLine(l, l == line)
+			}
+
+		case "help", "h":
+			fmt.Fprint(c.out, help)
+
+		default:
+			fmt.Fprintf(c.out, "Unknown command %q, type help for a list of commands\n", cmd)
+		}
+	}
+}
+
+func (c *cli) printLine(line int, current bool) {
+	if line < 1 || line > len(c.source) {
+		return
+	}
+
+	marker := " "
+	if current {
+		marker = ">"
+	}
+	fmt.Fprintf(c.out, "%s %4d | %s\n", marker, line, c.source[line-1])
+}
+
+func (c *cli) printVariables(vars []vm.Variable) {
+	for _, v := range vars {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, inspect(v.Value))
+	}
+}
+
+func (c *cli) lineArg(args []string) (int, bool) {
+	line, err := c.intArg(args)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return 0, false
+	}
+
+	if line < 1 {
+		fmt.Fprintf(c.out, "Invalid line %d\n", line)
+		return 0, false
+	}
+
+	return line, true
+}
+
+func (c *cli) intArg(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a single number")
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("expected a number, got %q", args[0])
+	}
+
+	return n, nil
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<unset>"
+	}
+
+	return obj.Inspect()
+}
@@ -0,0 +1,268 @@
+package debugger
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/vm"
+)
+
+// StopReason explains why the program was paused
+type StopReason string
+
+// Reasons for pausing the program
+const (
+	EntryStop      StopReason = "entry"
+	BreakpointStop StopReason = "breakpoint"
+	StepStop       StopReason = "step"
+	PauseStop      StopReason = "pause"
+)
+
+// ErrStopped is returned by Run when the program was stopped with Stop
+var ErrStopped = errors.New("stopped by the debugger")
+
+type stepMode int
+
+const (
+	modeContinue stepMode = iota
+	modeStepInto
+	modeStepOver
+	modeStepOut
+)
+
+// Debugger pauses a vm at breakpoints and after steps. While the program is
+// paused the Stopped callback runs on the goroutine executing the vm; it picks
+// how to resume by calling Continue or one of the step methods before it
+// returns, and may inspect the vm in the meantime. Breakpoints, Pause and Stop
+// may also be used from other goroutines while the program runs.
+type Debugger struct {
+	// Stopped is called whenever the program pauses. Returning an error stops
+	// the program, Run then returns it as a *vm.RuntimeError.
+	Stopped func(d *Debugger, reason StopReason) error
+
+	machine     *vm.VM
+	mu          sync.Mutex // guards breakpoints
+	breakpoints map[int]bool
+	pause       int32 // set by Pause, read atomically
+	stop        int32 // set by Stop, read atomically
+	mode        stepMode
+	startDepth  int   // call depth at which the current step started
+	lines       []int // line last executed by each active frame, indexed by depth-1
+	started     bool
+	stopped     bool // set once the program was stopped with Stop
+}
+
+// New constructs a debugger controlling the vm. The program pauses on its
+// first line unless Continue is called before it runs.
+func New(machine *vm.VM) *Debugger {
+	d := &Debugger{
+		machine:     machine,
+		breakpoints: make(map[int]bool),
+		mode:        modeStepInto,
+	}
+
+	machine.SetHooks(&vm.Hooks{
+		BeforeInstruction: d.beforeInstruction,
+		OnCall:            d.onCall,
+		OnReturn:          d.onReturn,
+	})
+
+	return d
+}
+
+// VM returns the vm controlled by the debugger
+func (d *Debugger) VM() *vm.VM {
+	return d.machine
+}
+
+// Run runs the program until it completes or Stopped returns an error
+func (d *Debugger) Run() error {
+	err := d.machine.Run()
+	if d.stopped {
+		return ErrStopped
+	}
+
+	return err
+}
+
+// SetBreakpoint pauses the program whenever it starts executing the line
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints[line] = true
+}
+
+// ClearBreakpoint removes the breakpoint on the line, reporting whether there was one
+func (d *Debugger) ClearBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := d.breakpoints[line]
+	delete(d.breakpoints, line)
+	return ok
+}
+
+// ClearBreakpoints removes every breakpoint
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool)
+}
+
+// Breakpoints returns the lines with breakpoints in ascending order
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// Continue resumes the program until it reaches a breakpoint
+func (d *Debugger) Continue() {
+	d.resume(modeContinue)
+}
+
+// StepInto resumes the program until it reaches another line, in any function
+func (d *Debugger) StepInto() {
+	d.resume(modeStepInto)
+}
+
+// StepOver resumes the program until it reaches another line in the current
+// function, or returns from it
+func (d *Debugger) StepOver() {
+	d.resume(modeStepOver)
+}
+
+// StepOut resumes the program until the current function returns
+func (d *Debugger) StepOut() {
+	d.resume(modeStepOut)
+}
+
+// Pause asks the running program to pause before its next instruction
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pause, 1)
+}
+
+// Stop asks the running program to stop before its next instruction, making
+// Run return ErrStopped. A paused program stops once it is resumed.
+func (d *Debugger) Stop() {
+	atomic.StoreInt32(&d.stop, 1)
+}
+
+// Lookup resolves a name as seen by a frame, where frame 0 is the innermost:
+// locals first, then the free variables of the closure and finally globals
+func (d *Debugger) Lookup(frameIndex int, name string) (object.Object, bool) {
+	scopes := [][]vm.Variable{
+		d.machine.Locals(frameIndex),
+		d.machine.FreeVariables(frameIndex),
+		d.machine.Globals(),
+	}
+
+	for _, vars := range scopes {
+		// A name defined twice refers to the latest definition that has a value
+		for i := len(vars) - 1; i >= 0; i-- {
+			if vars[i].Name == name && vars[i].Value != nil {
+				return vars[i].Value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func (d *Debugger) resume(mode stepMode) {
+	d.mode = mode
+	d.startDepth = d.machine.CallDepth()
+}
+
+func (d *Debugger) beforeInstruction(machine *vm.VM) error {
+	if atomic.LoadInt32(&d.stop) != 0 {
+		d.stopped = true
+		return ErrStopped
+	}
+
+	depth := machine.CallDepth()
+	for len(d.lines) < depth {
+		d.lines = append(d.lines, 0)
+	}
+	d.lines = d.lines[:depth]
+
+	// The program only pauses on the first instruction of a line
+	line := machine.Position().Line
+	newLine := line > 0 && d.lines[depth-1] != line
+	if line > 0 {
+		d.lines[depth-1] = line
+	}
+
+	var reason StopReason
+	switch {
+	case atomic.CompareAndSwapInt32(&d.pause, 1, 0):
+		reason = PauseStop
+	case newLine && !d.started && d.mode == modeStepInto:
+		reason = EntryStop
+	case newLine && d.hasBreakpoint(line):
+		reason = BreakpointStop
+	case newLine && d.mode == modeStepInto:
+		reason = StepStop
+	case newLine && d.mode == modeStepOver && depth <= d.startDepth:
+		reason = StepStop
+	case d.mode == modeStepOut && depth < d.startDepth:
+		reason = StepStop
+	}
+
+	if newLine {
+		d.started = true
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	d.Continue()
+	if d.Stopped == nil {
+		return nil
+	}
+
+	if err := d.Stopped(d, reason); err != nil {
+		return err
+	}
+
+	// Stop may have been called while the program was paused
+	if atomic.LoadInt32(&d.stop) != 0 {
+		d.stopped = true
+		return ErrStopped
+	}
+
+	return nil
+}
+
+func (d *Debugger) hasBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.breakpoints[line]
+}
+
+// onCall forgets the line of a frame that was previously at the new depth
+func (d *Debugger) onCall(machine *vm.VM) {
+	if depth := machine.CallDepth(); len(d.lines) >= depth {
+		d.lines = d.lines[:depth-1]
+	}
+}
+
+func (d *Debugger) onReturn(machine *vm.VM, value object.Object) {
+	if depth := machine.CallDepth(); len(d.lines) > depth {
+		d.lines = d.lines[:depth]
+	}
+}
@@ -0,0 +1,228 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/vm"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 3);
+puts(y);`
+
+func compile(t *testing.T, input string) *vm.VM {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetOutput(nil, nil)
+	return machine
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		actions     string // one action per stop: c, s, n or o
+		expected    []string
+	}{
+		{"step into", nil, "sssssss", []string{"1 main entry", "5 main step", "2 add step", "3 add step", "6 main step", "2 add step", "3 add step", "7 main step"}},
+		{"step over", nil, "nnnn", []string{"1 main entry", "5 main step", "6 main step", "7 main step"}},
+		{"breakpoints", []int{3}, "ccc", []string{"1 main entry", "3 add breakpoint", "3 add breakpoint"}},
+		{"step out", []int{2}, "coo", []string{"1 main entry", "2 add breakpoint", "5 main step", "2 add breakpoint"}},
+		{"step over hits breakpoint", []int{2}, "nncn", []string{"1 main entry", "5 main step", "2 add breakpoint", "2 add breakpoint", "3 add step"}},
+	}
+
+	for _, tt := range tests {
+		d := New(compile(t, program))
+		for _, line := range tt.breakpoints {
+			d.SetBreakpoint(line)
+		}
+
+		stops := []string{}
+		d.Stopped = func(d *Debugger, reason StopReason) error {
+			frame := d.VM().Frames()[0]
+			stops = append(stops, fmt.Sprintf("%d %s %s", frame.Pos.Line, frame.Name, reason))
+
+			if len(stops) > len(tt.actions) {
+				d.Continue()
+				return nil
+			}
+
+			switch tt.actions[len(stops)-1] {
+			case 'c':
+				d.Continue()
+			case 's':
+				d.StepInto()
+			case 'n':
+				d.StepOver()
+			case 'o':
+				d.StepOut()
+			}
+			return nil
+		}
+
+		if err := d.Run(); err != nil {
+			t.Fatalf("%s: vm error: %s", tt.name, err)
+		}
+
+		if strings.Join(stops, ", ") != strings.Join(tt.expected, ", ") {
+			t.Errorf("%s: wrong stops.\nwant=%q\ngot= %q", tt.name, tt.expected, stops)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	input := `let scale = 10;
+let make = fn(factor) {
+  fn(n) {
+    let scaled = n * factor * scale;
+    scaled
+  }
+};
+make(2)(3);`
+
+	d := New(compile(t, input))
+	d.SetBreakpoint(5)
+
+	got := map[string]string{}
+	d.Stopped = func(d *Debugger, reason StopReason) error {
+		d.Continue()
+		if reason != BreakpointStop {
+			return nil
+		}
+
+		for _, name := range []string{"n", "scaled", "factor", "scale", "make", "missing"} {
+			if value, ok := d.Lookup(0, name); ok {
+				got[name] = value.Inspect()
+			}
+		}
+
+		// Frame 1 is the main program, which only sees globals
+		if _, ok := d.Lookup(1, "n"); ok {
+			t.Errorf("main frame resolved local n")
+		}
+		return nil
+	}
+
+	if err := d.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	expected := map[string]string{"n": "3", "scaled": "60", "factor": "2", "scale": "10"}
+	for name, value := range expected {
+		if got[name] != value {
+			t.Errorf("wrong value for %s. want=%q, got=%q", name, value, got[name])
+		}
+	}
+
+	if _, ok := got["missing"]; ok {
+		t.Errorf("resolved an undefined name")
+	}
+	if _, ok := got["make"]; !ok {
+		t.Errorf("did not resolve global make")
+	}
+}
+
+func TestCLI(t *testing.T) {
+	input := strings.Join([]string{
+		"break 2",
+		"continue",
+		"bt",
+		"locals",
+		"print b",
+		"print nope",
+		"next",
+		"locals",
+		"frame 1",
+		"breakpoints",
+		"delete 2",
+		"continue",
+	}, "\n")
+
+	var out strings.Builder
+	err := Start(compile(t, program), program, strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `Stopped at 1:11 in main (entry)
+>    1 | let add = fn(a, b) {
+(debug) Breakpoint set at line 2
+(debug) Stopped at 2:13 in add (breakpoint)
+>    2 |   let sum = a + b;
+(debug) * #0 add at 2:13
+  #1 main at 5:12
+(debug) a = 1
+b = 2
+sum = <unset>
+(debug) b = 2
+(debug) No variable named nope
+(debug) Stopped at 3:3 in add (step)
+>    3 |   sum
+(debug) a = 1
+b = 2
+sum = 3
+(debug) #1 main at 5:12
+>    5 | let x = add(1, 2);
+(debug)      2 |   let sum = a + b;
+(debug) Breakpoint removed from line 2
+(debug) Program finished
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot= %q", expected, out.String())
+	}
+}
+
+func TestCLIQuit(t *testing.T) {
+	var out strings.Builder
+	err := Start(compile(t, program), program, strings.NewReader("step\nquit\n"), &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if strings.Contains(out.String(), "Program finished") {
+		t.Errorf("program finished after quit:\n%s", out.String())
+	}
+}
+
+func TestPauseAndStop(t *testing.T) {
+	d := New(compile(t, program))
+	d.Continue()
+	d.Pause()
+
+	reasons := []StopReason{}
+	d.Stopped = func(d *Debugger, reason StopReason) error {
+		reasons = append(reasons, reason)
+		d.Stop()
+		return nil
+	}
+
+	if err := d.Run(); err != ErrStopped {
+		t.Fatalf("wrong error. want=%v, got=%v", ErrStopped, err)
+	}
+
+	if len(reasons) != 1 || reasons[0] != PauseStop {
+		t.Errorf("wrong stops. want=[%s], got=%v", PauseStop, reasons)
+	}
+}
@@ -2,18 +2,36 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/lukeomalley/monkey_lang/ast"
 	"github.com/lukeomalley/monkey_lang/object"
 )
 
+// MaxFrames limits the nesting of function calls, like the frames of the vm, so
+// that runaway recursion fails with an error instead of exhausting the Go stack
+const MaxFrames = 1024
+
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	NULL  = object.NULL
 )
 
+// Eval evaluates the node within the environment. Errors are annotated with the
+// position of the innermost node that produced them.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		return &object.Error{Message: err.Message, Pos: node.Pos()}
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -41,6 +59,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -67,13 +87,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.StructStatement:
+		evalStructStatement(node, env)
+
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -86,7 +112,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -108,9 +134,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalIndexExpression(left, index)
 
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
+	case *ast.SelectorExpression:
+		return evalSelectorExpression(node, env)
+
 	}
 
 	return nil
@@ -135,15 +167,82 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := env.Builtins().Lookup(node.Value); ok {
 		return builtin
 	}
 
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
+func evalSelectorExpression(node *ast.SelectorExpression, env *object.Environment) object.Object {
+	// Selectors such as math.abs name a builtin, unless a variable shadows the
+	// namespace
+	if name, ok := node.QualifiedName(); ok {
+		if _, isVariable := env.Get(node.Left.String()); !isVariable {
+			if builtin, ok := env.Builtins().Lookup(name); ok {
+				return builtin
+			}
+
+			return newError("identifier not found: " + name)
+		}
+	}
+
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	s, ok := left.(*object.Struct)
+	if !ok {
+		return newError("field access not supported: %s", left.Type())
+	}
+
+	value, err := s.Get(node.Field.Value)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return value
+}
+
+func evalStructStatement(node *ast.StructStatement, env *object.Environment) {
+	fields := make([]string, len(node.Fields))
+	for i, f := range node.Fields {
+		fields[i] = f.Value
+	}
+
+	env.Set(node.Name.Value, &object.StructType{Name: node.Name.Value, Fields: fields})
+}
+
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	left := Eval(node.Target.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	s, ok := left.(*object.Struct)
+	if !ok {
+		return newError("field access not supported: %s", left.Type())
+	}
+
+	if err := s.Set(node.Target.Field.Value, value); err != nil {
+		return newError("%s", err)
+	}
+
+	return nil
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("internal error: %v", r)
+		}
+	}()
 
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
@@ -183,17 +282,20 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 
-	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
@@ -202,13 +304,23 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -223,6 +335,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
@@ -237,6 +352,37 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// evalFloatInfixExpression evaluates operators on floats, or a float and an
+// integer, in which case the integer is promoted to a float
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.FloatValue(left)
+	rightVal, _ := object.FloatValue(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -279,34 +425,93 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-
-	return &object.Integer{Value: -value}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with the arguments. Builtins write their output to the
+// writers of env, the environment of the caller.
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+
+		// The program counts as a frame, as it does in the vm
+		depth := env.CallDepth() + 1
+		if depth >= MaxFrames {
+			return newError("stack overflow: exceeded %d nested calls", MaxFrames)
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv.SetCallDepth(depth)
 		evaluated := Eval(fn.Body, extendedEnv)
+		if evaluated == nil {
+			return NULL
+		}
+
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		if result := fn.Fn(callContext{env: env}, args...); result != nil {
 			return result
 		}
 
 		return NULL
 
+	case *object.StructType:
+		instance, err := fn.New(args)
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		return instance
+
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// Call applies a function or builtin to the arguments. It lets hosts invoke
+// functions returned from a script. Builtins called directly print to os.Stdout
+// and os.Stderr.
+func Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, nil)
+}
+
+// callContext lets builtins call back into the evaluator and print to the
+// output of the calling environment
+type callContext struct {
+	env *object.Environment
+}
+
+func (c callContext) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, c.env)
+}
+
+func (c callContext) Stdout() io.Writer {
+	if c.env == nil {
+		return os.Stdout
+	}
+
+	return c.env.Stdout()
+}
+
+func (c callContext) Stderr() io.Writer {
+	if c.env == nil {
+		return os.Stderr
+	}
+
+	return c.env.Stderr()
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
@@ -319,16 +524,17 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
+
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
 
-	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
+		if !object.IsHashable(key) {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
@@ -337,17 +543,15 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
-
+		hash.Set(key, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+	case left.Type() == object.ARRAY_OBJ:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
@@ -356,9 +560,42 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	// Omitted bounds are passed as null
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+
+		bounds[i] = Eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	slice, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return slice
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return newError("array index must be INTEGER. got=%s", index.Type())
+	}
+
+	idx := integer.Value
 	max := int64(len(arrayObject.Elements) - 1)
 
 	if idx < 0 || idx > max {
@@ -371,17 +608,16 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
-	if !ok {
+	if !object.IsHashable(index) {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 // ============================================================================
@@ -396,6 +632,11 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := object.FloatValue(obj)
+	return ok
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/lukeomalley/monkey_lang/lexer"
@@ -37,6 +38,33 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5", "2.5"},
+		{"-2.5", "-2.5"},
+		{"1.5 + 1.25", "2.75"},
+		{"1 + 0.5", "1.5"},
+		{"0.5 * 4", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"1.5 > 1", "true"},
+		{"2 == 2.0", "true"},
+		{"math.sqrt(2.25) + math.pow(2, 3)", "9.5"},
+		{"math.floor(-2.5)", "-3"},
+		{"math.pow(3, 40)", "Error: 1:9: integer overflow in `math.pow`: 3 ** 40"},
+		{"math.max([1, 5.5, 2])", "5.5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -61,6 +89,21 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" != "a"`, false},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"" < "a"`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`1 == "1"`, false},
+		{`"1" != 1`, true},
+		{"1 == true", false},
+		{"[] == {}", false},
 	}
 
 	for _, tt := range tests {
@@ -181,10 +224,16 @@ func TestErrorHandling(t *testing.T) {
 		`, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
 		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`1 < "a"`, "type mismatch: INTEGER < STRING"},
+		{"[1] > [0]", "unknown operator: ARRAY > ARRAY"},
 		{
 			`{"name": "Monkey"}[fn(x) { x }]`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{[fn(x) { x }]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
 	}
 
 	for _, tt := range tests {
@@ -202,6 +251,44 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "1:3: division by zero"},
+		{"let div = fn(a, b) {\n  a / b\n};\ndiv(10, 0)", "2:5: division by zero"},
+		{`[1, 2]["a"]`, "1:7: array index must be INTEGER. got=STRING"},
+		{`1[1:]`, "1:2: slice operator not supported: INTEGER"},
+		{`[1]["a":]`, "1:4: slice index must be INTEGER. got=STRING"},
+		{`fn(a, b) { a + b; }(1);`, "1:20: wrong number of arguments: want=2, got=1"},
+		{"let x = 1;\nx + true", "2:3: type mismatch: INTEGER + BOOLEAN"},
+		{`struct P { x }; P(1).y`, "1:21: unknown field y in P"},
+		{`struct P { x }; P(1, 2)`, "1:18: wrong number of arguments: want=1, got=2"},
+		{`let a = 1; a.x = 2`, "1:12: field access not supported: INTEGER"},
+		{`[1].x`, "1:4: field access not supported: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		got := errObj.Pos.String() + ": " + errObj.Message
+		if got != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionWithoutValue(t *testing.T) {
+	evaluated := testEval("fn() { let a = 1; }() == fn() {}()")
+	testBooleanObject(t, evaluated, true)
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -332,6 +419,305 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`trim("  monkey  ")`, "monkey"},
+		{`trim("xxmonkeyx", "x")`, "monkey"},
+		{`upper("Monkey") + lower("Monkey")`, "MONKEYmonkey"},
+		{`contains("monkey", "key")`, "true"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`index_of("monkey", "key")`, "3"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`chars("héllo")`, "[h, é, l, l, o]"},
+		{`format("%s has %d items", "cart", 3)`, "cart has 3 items"},
+		{`format("%.2f of %v", 0.5, 1.25)`, "0.50 of 1.25"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %s: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 10)`, "20"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort_by(["ccc", "a", "bb"], fn(s) { len(s) })`, "[a, bb, ccc]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`range(1, 7, 2)`, "[1, 3, 5]"},
+		{`range(9223372036854775806, 9223372036854775807, 3)`, "[9223372036854775806]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`enumerate(["a", "b"])`, "[[0, a], [1, b]]"},
+		{`contains([1, [2]], [2])`, "true"},
+		{`keys({"b": 1, "a": 2})`, "[b, a]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}"},
+		{`flatten([1, [2, [3]]])`, "[1, 2, [3]]"},
+		{`unique([1, 2, 1, 3, 2])`, "[1, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %s: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("[1, 2.5, [true, null]]")`, "[1, 2.5, [true, null]]"},
+		{`json_stringify({"b": [1, 2.5], "a": true})`, `{"b":[1,2.5],"a":true}`},
+		{`json_stringify([1, [2]], 1)`, "[\n 1,\n [\n  2\n ]\n]"},
+		{`json_stringify(1.0)`, "1.0"},
+		{`json_parse(json_stringify({"k": [1, "two"]}))`, "{k: [1, two]}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %s: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(first([]))`, "NULL"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`let x = 1; type(fn() { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`str(42) + "!"`, "42!"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`int("42") + 1`, "43"},
+		{`int(" -7 ")`, "-7"},
+		{`int(2.9)`, "2"},
+		{`int(true)`, "1"},
+		{`bool(0)`, "true"},
+		{`bool(first([]))`, "false"},
+		{`inspect("a")`, `"a"`},
+		{`inspect(["a", 1, {"k": "v"}])`, `["a", 1, {"k": "v"}]`},
+		{`arity(fn(a, b) { a })`, "2"},
+		{`arity(len)`, "1"},
+		{`arity(range)`, "1"},
+		{`let add = fn(a, b) { a + b }; name(add)`, "add"},
+		{`name(fn() {})`, "null"},
+		{`name(math.abs)`, "math.abs"},
+		{`let outer = fn() { let inner = fn() { 1 }; name(inner) }; outer()`, "inner"},
+		{`map([1, "a", true], type)`, "[INTEGER, STRING, BOOLEAN]"},
+	}
+
+	for _, tt := range tests {
+		result := testEval(tt.input)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`int("4x")`, `could not convert "4x" to INTEGER`},
+		{`int([])`, "argument to `int` not supported. got=ARRAY"},
+		{`arity(1)`, "argument to `arity` must be a FUNCTION. got=INTEGER"},
+		{`name("f")`, "argument to `name` must be a FUNCTION. got=STRING"},
+	}
+
+	for _, tt := range errors {
+		result, ok := (testEval(tt.input)).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %s", tt.input)
+			continue
+		}
+
+		if result.Message != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%q", tt.input, tt.expected, result.Message)
+		}
+	}
+}
+
+func TestOutputRedirection(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{`puts("a", 1, [true])`, "a\n1\n[true]\n", ""},
+		{`print("a", 1); print("b")`, "a1b", ""},
+		{`eprint("oops"); puts("done")`, "done\n", "oops\n"},
+		{`map([1, 2], fn(x) { print(x) })`, "12", ""},
+		{`let f = fn() { eprint(json_stringify([1])) }; f()`, "", "[1]\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		env := object.NewEnvironment()
+		env.SetOutput(&stdout, &stderr)
+
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if isError(evaluated) {
+			t.Fatalf("unexpected error for %s: %s", tt.input, evaluated.Inspect())
+		}
+
+		if stdout.String() != tt.stdout {
+			t.Errorf("wrong stdout for %s. want=%q, got=%q", tt.input, tt.stdout, stdout.String())
+		}
+
+		if stderr.String() != tt.stderr {
+			t.Errorf("wrong stderr for %s. want=%q, got=%q", tt.input, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestHostBuiltins(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Namespace("host").Register("double", 1, func(ctx object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`host.double(21)`, 42},
+		{`let f = fn(x) { host.double(x) }; f(4)`, 8},
+		{`host.double(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`host.triple(1)`, "identifier not found: host.triple"},
+		{`let host = 1; host.double(1)`, "field access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		env := object.NewEnvironmentWithBuiltins(builtins)
+
+		evaluated := Eval(program, env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = fn() { x() }; x()", "Error: 1:17: stack overflow: exceeded 1024 nested calls"},
+		{"let f = fn(n) { map([n], fn(m) { f(m + 1) }) }; f(0)", "Error: 1:20: stack overflow: exceeded 1024 nested calls"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1022)", "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBuiltinsCallingFunctions(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Register("each", 2, func(ctx object.CallContext, args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		result := make([]object.Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			result[i] = ctx.Call(args[1], el)
+		}
+		return &object.Array{Elements: result}
+	})
+
+	tests := []struct {
+		input    string
+		expected []int64
+	}{
+		{`each([1, 2, 3], fn(x) { x * 2 })`, []int64{2, 4, 6}},
+		{`let offset = 10; each([1, 2], fn(x) { x + offset })`, []int64{11, 12}},
+		{`each([[1], [2, 3]], len)`, []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		evaluated := Eval(program, object.NewEnvironmentWithBuiltins(builtins))
+		result, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if len(result.Elements) != len(tt.expected) {
+			t.Errorf("wrong number of elements. want=%d, got=%d", len(tt.expected), len(result.Elements))
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			testIntegerObject(t, result.Elements[i], expected)
+		}
+	}
+
+	fn := testEval(`let base = 40; fn(x) { base + x }`)
+	testIntegerObject(t, Call(fn, &object.Integer{Value: 2}), 42)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -379,6 +765,75 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][-10:10]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"let a = [1, 2, 3]; let n = 2; a[n - 1:n + 1]", "[2, 3]"},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[:3]`, "mon"},
+		{`"monkey"[3:]`, "key"},
+		{`"monkey"[-3:]`, "key"},
+		{`"monkey"[4:2]`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %q: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong slice for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }; let p = Point(1, 2); p.x + p.y", "3"},
+		{"struct Point { x, y }; let p = Point(1, 2); p.x = 10; p.x * p.y", "20"},
+		{"struct Point { x, y }; let p = Point(1, 2); let q = p; q.x = 5; p.x", "5"},
+		{"struct Point { x, y }; Point(1, [2])", "Point{x: 1, y: [2]}"},
+		{"struct Point { x, y }; type(Point(1, 2))", "Point"},
+		{"struct Point { x, y }; Point(1, 2) == Point(1.0, 2)", "true"},
+		{"struct Point { x, y }; struct Line { from, to }; let l = Line(Point(0, 0), Point(1, 2)); l.to.y = 5; l.to.y", "5"},
+		{"struct Point { x, y }; let move = fn(p, dx) { if (dx > 0) { p.x = p.x + dx } p }; move(Point(1, 2), 3).x", "4"},
+		{"let f = fn(n) { struct Box { value }; Box(n) }; f(1).value + f(2).value", "3"},
+		{"struct Point { x, y }; map([1, 2], fn(n) { Point(n, n * 2) })[1].y", "4"},
+		{"struct Point { x, y }; Point", "struct Point { x, y }"},
+		{"struct Node { v, next }; let a = Node(1, 0); let b = Node(1, 0); a.next = a; b.next = b; a == b", "true"},
+		{"struct Node { v, next }; let a = Node(1, 0); let b = Node(1, 0); a.next = a; b.next = Node(2, b); a == b", "false"},
+		{"struct Node { v, next }; let a = Node(1, 0); a.next = a; a", "Node{v: 1, next: <cycle>}"},
+		{`struct Node { v, next }; let a = Node("a", 0); a.next = [a, {"b": a}]; inspect(a)`, `Node{v: "a", next: [<cycle>, {"b": <cycle>}]}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %q: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -405,20 +860,33 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                            6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, pair := range result.Pairs() {
+		key, _ := object.HashKeyOf(pair.Key)
+		expectedValue, ok := expected[key]
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("unexpected key in Pairs: %s", pair.Key.Inspect())
+			continue
 		}
 
 		testIntegerObject(t, pair.Value, expectedValue)
 	}
 }
 
+func TestHashInspectOrder(t *testing.T) {
+	input := `let h = {"zebra": 1, "apple": 2, [1, 2]: 3, true: 4}; h`
+	expected := "{zebra: 1, apple: 2, [1, 2]: 3, true: 4}"
+
+	for i := 0; i < 10; i++ {
+		if got := testEval(input).Inspect(); got != expected {
+			t.Fatalf("wrong hash output. want=%q, got=%q", expected, got)
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -428,6 +896,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{"foo": 5}["foo"]`,
 			5,
 		},
+		{
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			5,
+		},
+		{
+			`{[1, "a"]: 5}[["a", 1]]`,
+			nil,
+		},
 		{
 			`{"foo": 5}["bar"]`,
 			nil,
@@ -0,0 +1,199 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// edit is a line of a diff, kept, removed or added
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// Diff returns a unified diff turning old into new, or an empty string when
+// they are equal. The sides are labelled name.orig and name.
+func Diff(name, old, new string) string {
+	if old == new {
+		return ""
+	}
+
+	edits := diffLines(splitLines(old), splitLines(new))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+
+	for start := 0; start < len(edits); {
+		first := nextChange(edits, start)
+		if first == len(edits) {
+			break
+		}
+
+		// A hunk extends while the changes are close enough to share context
+		last := first
+		for {
+			next := nextChange(edits, last+1)
+			if next == len(edits) || next-last > 2*context {
+				break
+			}
+			last = next
+		}
+
+		from := max(first-context, 0)
+		to := min(last+context+1, len(edits))
+		writeHunk(&out, edits, from, to)
+		start = to
+	}
+
+	return out.String()
+}
+
+func nextChange(edits []edit, from int) int {
+	for i := from; i < len(edits); i++ {
+		if edits[i].op != ' ' {
+			return i
+		}
+	}
+	return len(edits)
+}
+
+func writeHunk(out *bytes.Buffer, edits []edit, from, to int) {
+	oldStart, newStart := 1, 1
+	for _, e := range edits[:from] {
+		if e.op != '+' {
+			oldStart++
+		}
+		if e.op != '-' {
+			newStart++
+		}
+	}
+
+	oldLen, newLen := 0, 0
+	for _, e := range edits[from:to] {
+		if e.op != '+' {
+			oldLen++
+		}
+		if e.op != '-' {
+			newLen++
+		}
+	}
+
+	// An empty side starts at the line before the hunk
+	if oldLen == 0 {
+		oldStart--
+	}
+	if newLen == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+	for _, e := range edits[from:to] {
+		out.WriteByte(e.op)
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits text into lines that keep their newline
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds a shortest edit script with the Myers algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// The furthest reaching path of every diagonal, before each step
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edits
+	edits := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{'+', b[y-1]})
+			} else {
+				edits = append(edits, edit{'-', a[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package format
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"let x=1\nputs(x)\n",
+			"let x = 1;\nputs(x);\n",
+			"--- f.mk.orig\n+++ f.mk\n@@ -1,2 +1,2 @@\n-let x=1\n-puts(x)\n+let x = 1;\n+puts(x);\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			"--- f.mk.orig\n+++ f.mk\n" +
+				"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			"a\nb\nc",
+			"a\nb\nc\n",
+			"--- f.mk.orig\n+++ f.mk\n@@ -1,3 +1,3 @@\n a\n b\n-c\n\\ No newline at end of file\n+c\n",
+		},
+		{
+			"",
+			"x;\n",
+			"--- f.mk.orig\n+++ f.mk\n@@ -0,0 +1,1 @@\n+x;\n",
+		},
+		{
+			"a\nx\nb\n",
+			"a\nb\n",
+			"--- f.mk.orig\n+++ f.mk\n@@ -1,3 +1,2 @@\n a\n-x\n b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Diff("f.mk", tt.old, tt.new); got != tt.expected {
+			t.Errorf("wrong diff of %q and %q.\nwant=%q\ngot= %q", tt.old, tt.new, tt.expected, got)
+		}
+	}
+}
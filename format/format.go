@@ -0,0 +1,475 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// indent is the indentation of each nested block
+const indent = "  "
+
+// Source formats Monkey source code, keeping its comments. Source that does not
+// parse is returned with the first syntax error.
+func Source(src string) (string, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.SyntaxErrors(); len(errs) != 0 {
+		return "", errs[0]
+	}
+
+	pr := &printer{lines: strings.Split(src, "\n"), closing: make(map[token.Position]token.Position)}
+	pr.scan(src)
+
+	pr.statements(program.Statements)
+	pr.flushComments(token.Position{Line: len(pr.lines) + 1})
+
+	return pr.out.String(), nil
+}
+
+// Node formats a single node without the surrounding source
+func Node(node ast.Node) string {
+	pr := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.statements(node.Statements)
+		return pr.out.String()
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node, lowest)
+	}
+
+	return pr.out.String()
+}
+
+type printer struct {
+	out   bytes.Buffer
+	depth int
+
+	// Only set when formatting source
+	lines    []string                          // lines of the source, used to keep blank lines
+	comments []token.Token                     // comments not printed yet, in source order
+	closing  map[token.Position]token.Position // closing brace or bracket of each opening one
+
+	open bool // nothing was printed yet within the current block or literal
+}
+
+// scan collects the comments of the source and pairs its braces and brackets
+func (p *printer) scan(src string) {
+	open := []token.Position{}
+
+	l := lexer.NewWithComments(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.COMMENT:
+			p.comments = append(p.comments, tok)
+		case token.LBRACE, token.LBRACKET:
+			open = append(open, tok.Pos)
+		case token.RBRACE, token.RBRACKET:
+			if len(open) > 0 {
+				p.closing[open[len(open)-1]] = tok.Pos
+				open = open[:len(open)-1]
+			}
+		}
+	}
+}
+
+func (p *printer) statements(stmts []ast.Statement) {
+	p.open = true
+	for _, stmt := range stmts {
+		p.flushComments(stmt.Pos())
+		p.line(stmt.Pos(), func() { p.statement(stmt) })
+	}
+}
+
+// line writes a line at the current indentation, after a blank line when one
+// separated it from the previous line in the source
+func (p *printer) line(pos token.Position, write func()) {
+	if !p.open && p.blankLineBefore(pos) {
+		p.out.WriteString("\n")
+	}
+
+	p.writeIndent()
+	write()
+	p.out.WriteString("\n")
+	p.open = false
+}
+
+// flushComments writes the comments preceding a position. A comment following
+// code on its line stays at the end of the last line written.
+func (p *printer) flushComments(pos token.Position) {
+	for len(p.comments) > 0 && before(p.comments[0].Pos, pos) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if !p.startsLine(comment.Pos) && p.out.Len() > 0 {
+			p.out.Truncate(p.out.Len() - 1)
+			p.out.WriteString(" " + comment.Literal + "\n")
+			continue
+		}
+
+		p.line(comment.Pos, func() { p.out.WriteString(comment.Literal) })
+	}
+}
+
+// hasCommentsBefore reports whether a comment is waiting to be printed before a position
+func (p *printer) hasCommentsBefore(pos token.Position) bool {
+	return len(p.comments) > 0 && before(p.comments[0].Pos, pos)
+}
+
+// blankLineBefore reports whether a blank line preceded the line starting at
+// the position, which is kept to group statements
+func (p *printer) blankLineBefore(pos token.Position) bool {
+	if pos.Line < 2 || pos.Line > len(p.lines) || !p.startsLine(pos) {
+		return false
+	}
+
+	return strings.TrimSpace(p.lines[pos.Line-2]) == ""
+}
+
+// startsLine reports whether only whitespace precedes the position on its line
+func (p *printer) startsLine(pos token.Position) bool {
+	if pos.Line < 1 || pos.Line > len(p.lines) {
+		return true
+	}
+
+	return strings.TrimSpace(p.lines[pos.Line-1][:pos.Column-1]) == ""
+}
+
+// multiline reports whether the brace or bracket at the position was closed on
+// a later line, returning the position of the closing one
+func (p *printer) multiline(pos token.Position) (token.Position, bool) {
+	end, ok := p.closing[pos]
+	return end, ok && end.Line > pos.Line
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let ")
+		p.binding(stmt.Name)
+		p.out.WriteString(" = ")
+		p.expression(stmt.Value, lowest)
+		p.out.WriteString(";")
+
+	case *ast.StructStatement:
+		p.out.WriteString("struct " + stmt.Name.Value + " ")
+		p.fields(stmt)
+
+	case *ast.AssignStatement:
+		p.expression(stmt.Target, lowest)
+		p.out.WriteString(" = ")
+		p.expression(stmt.Value, lowest)
+		p.out.WriteString(";")
+
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if stmt.ReturnValue != nil {
+			p.out.WriteString(" ")
+			p.expression(stmt.ReturnValue, lowest)
+		}
+		p.out.WriteString(";")
+
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, lowest)
+
+		// Expressions ending with a block read as statements without a semicolon
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok {
+			p.out.WriteString(";")
+		}
+
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	end, ok := p.closing[block.Token.Pos]
+	if len(block.Statements) == 0 && !(ok && p.hasCommentsBefore(end)) {
+		p.out.WriteString("{}")
+		return
+	}
+
+	p.out.WriteString("{\n")
+	p.depth++
+	p.statements(block.Statements)
+	if ok {
+		p.flushComments(end)
+	}
+	p.depth--
+	p.writeIndent()
+	p.out.WriteString("}")
+}
+
+// =============================================================================
+// Expressions
+// =============================================================================
+
+// Operator precedences, mirroring the parser
+const (
+	lowest = iota
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	postfix // calls, indexes and selectors
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+// expression writes an expression within a context of the given precedence,
+// adding the parentheses needed to keep its meaning
+func (p *printer) expression(exp ast.Expression, precedence int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(exp.Value)
+
+	case *ast.IntegerLiteral:
+		p.out.WriteString(exp.Token.Literal)
+
+	case *ast.FloatLiteral:
+		p.out.WriteString(exp.Token.Literal)
+
+	case *ast.Boolean:
+		p.out.WriteString(exp.Token.Literal)
+
+	case *ast.StringLiteral:
+		p.out.WriteString(`"` + exp.Value + `"`)
+
+	case *ast.PrefixExpression:
+		p.parenthesize(precedence > prefix, func() {
+			p.out.WriteString(exp.Operator)
+			p.expression(exp.Right, prefix)
+		})
+
+	case *ast.InfixExpression:
+		prec := precedences[exp.Operator]
+		p.parenthesize(precedence > prec, func() {
+			p.expression(exp.Left, prec)
+			p.out.WriteString(" " + exp.Operator + " ")
+			// Operators are left associative, so an equal right operand was grouped
+			p.expression(exp.Right, prec+1)
+		})
+
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(exp.Condition, lowest)
+		p.out.WriteString(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(exp.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		p.out.WriteString("fn(")
+		for i, param := range exp.Parameters {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.binding(param)
+		}
+		p.out.WriteString(")")
+		if exp.ReturnType != nil {
+			p.out.WriteString(": " + exp.ReturnType.String())
+		}
+		p.out.WriteString(" ")
+		p.block(exp.Body)
+
+	case *ast.CallExpression:
+		p.callee(exp.Function)
+		p.out.WriteString("(")
+		p.expressionList(exp.Arguments)
+		p.out.WriteString(")")
+
+	case *ast.IndexExpression:
+		p.callee(exp.Left)
+		p.out.WriteString("[")
+		p.expression(exp.Index, lowest)
+		p.out.WriteString("]")
+
+	case *ast.SliceExpression:
+		p.callee(exp.Left)
+		p.out.WriteString("[")
+		if exp.Start != nil {
+			p.expression(exp.Start, lowest)
+		}
+		p.out.WriteString(":")
+		if exp.End != nil {
+			p.expression(exp.End, lowest)
+		}
+		p.out.WriteString("]")
+
+	case *ast.SelectorExpression:
+		p.callee(exp.Left)
+		p.out.WriteString("." + exp.Field.Value)
+
+	case *ast.ArrayLiteral:
+		elements := make([]func(), len(exp.Elements))
+		for i, el := range exp.Elements {
+			el := el
+			elements[i] = func() { p.expression(el, lowest) }
+		}
+		p.literal(exp.Token.Pos, "[", "]", exp.Elements, elements)
+
+	case *ast.HashLiteral:
+		pairs := make([]func(), len(exp.Keys))
+		for i, key := range exp.Keys {
+			key := key
+			pairs[i] = func() {
+				p.expression(key, lowest)
+				p.out.WriteString(": ")
+				p.expression(exp.Pairs[key], lowest)
+			}
+		}
+		p.literal(exp.Token.Pos, "{", "}", exp.Keys, pairs)
+
+	default:
+		panic(fmt.Sprintf("format: unexpected expression %T", exp))
+	}
+}
+
+// binding writes a let name or parameter with its annotation
+func (p *printer) binding(ident *ast.Identifier) {
+	p.out.WriteString(ident.Value)
+	if ident.Type != nil {
+		p.out.WriteString(": " + ident.Type.String())
+	}
+}
+
+// fields writes the fields of a struct declaration, spaced within the braces
+// when they fit on one line
+func (p *printer) fields(stmt *ast.StructStatement) {
+	starts := make([]ast.Expression, len(stmt.Fields))
+	fields := make([]func(), len(stmt.Fields))
+	for i, field := range stmt.Fields {
+		field := field
+		starts[i] = field
+		fields[i] = func() { p.binding(field) }
+	}
+
+	if _, ok := p.multiline(stmt.Brace.Pos); ok || len(fields) == 0 {
+		p.literal(stmt.Brace.Pos, "{", "}", starts, fields)
+		return
+	}
+
+	p.out.WriteString("{ ")
+	for i, write := range fields {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		write()
+	}
+	p.out.WriteString(" }")
+}
+
+// callee writes the operand of a call, index or selector
+func (p *printer) callee(exp ast.Expression) {
+	// A function literal is wrapped so it reads as the thing being called
+	if _, ok := exp.(*ast.FunctionLiteral); ok {
+		p.parenthesize(true, func() { p.expression(exp, lowest) })
+		return
+	}
+
+	p.expression(exp, postfix)
+}
+
+// literal writes an array or hash literal. A literal written over several lines
+// keeps one element per line, along with the comments between them.
+func (p *printer) literal(pos token.Position, open, close string, starts []ast.Expression, elements []func()) {
+	end, ok := p.multiline(pos)
+	if !ok || len(elements) == 0 && !p.hasCommentsBefore(end) {
+		p.out.WriteString(open)
+		for i, write := range elements {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			write()
+		}
+		p.out.WriteString(close)
+		return
+	}
+
+	p.out.WriteString(open + "\n")
+	p.depth++
+	p.open = true
+	for i, write := range elements {
+		start := startOf(starts[i])
+		p.flushComments(start)
+
+		// The separator is written before the comments following the element
+		last := i == len(elements)-1
+		p.line(start, func() {
+			write()
+			if !last {
+				p.out.WriteString(",")
+			}
+		})
+	}
+	p.flushComments(end)
+	p.depth--
+	p.writeIndent()
+	p.out.WriteString(close)
+}
+
+// startOf returns the position of the first token of an expression, the
+// position of infix and postfix expressions being that of their operator
+func startOf(exp ast.Expression) token.Position {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return startOf(exp.Left)
+	case *ast.CallExpression:
+		return startOf(exp.Function)
+	case *ast.IndexExpression:
+		return startOf(exp.Left)
+	case *ast.SliceExpression:
+		return startOf(exp.Left)
+	case *ast.SelectorExpression:
+		return startOf(exp.Left)
+	default:
+		return exp.Pos()
+	}
+}
+
+func (p *printer) expressionList(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(exp, lowest)
+	}
+}
+
+func (p *printer) parenthesize(needed bool, write func()) {
+	if needed {
+		p.out.WriteString("(")
+	}
+	write()
+	if needed {
+		p.out.WriteString(")")
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.out.WriteString(strings.Repeat(indent, p.depth))
+}
@@ -0,0 +1,160 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let add = fn(a,b) { return a+b }; add(1,2)", "let add = fn(a, b) {\n  return a + b;\n};\nadd(1, 2);\n"},
+		{"let f = fn() {}", "let f = fn() {};\n"},
+		{"if (x > 1) { puts(x) } else { puts(1); }", "if (x > 1) {\n  puts(x);\n} else {\n  puts(1);\n}\n"},
+		{"(1 + 2) * 3 - (4 - 5) + -(2 + 3)", "(1 + 2) * 3 - (4 - 5) + -(2 + 3);\n"},
+		{"1 - (2 - 3); (1 - 2) - 3; a * (b / c)", "1 - (2 - 3);\n1 - 2 - 3;\na * (b / c);\n"},
+		{"!(a == b); (-a)[0]; -a[0]", "!(a == b);\n(-a)[0];\n-a[0];\n"},
+		{"fn(x) { x }(2)", "(fn(x) {\n  x;\n})(2);\n"},
+		{`{"a": 1, 2: [1,2][0:], "b": m.k}`, "{\"a\": 1, 2: [1, 2][0:], \"b\": m.k};\n"},
+		{"let a = 1;\n\n\n\nlet b = 2; let c = 3;\n\nreturn 1.50", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n\nreturn 1.50;\n"},
+		{"let f = fn() {\n  let a = 1;\n\n  a\n}", "let f = fn() {\n  let a = 1;\n\n  a;\n};\n"},
+		{"let n:int=1; let f = fn(a:[int],b) :{string:fn(int):int} { {} }", "let n: int = 1;\nlet f = fn(a: [int], b): {string: fn(int): int} {\n  {};\n};\n"},
+		{"struct Point{x,y:int} struct Empty {}\np.x=p.y+1", "struct Point { x, y: int }\nstruct Empty {}\np.x = p.y + 1;\n"},
+		{"struct User {\n  name: string, // full name\n  age,\n}", "struct User {\n  name: string, // full name\n  age\n}\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("could not format %q: %s", tt.input, err)
+			continue
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("wrong formatting of %q.\nwant=%q\ngot= %q", tt.input, tt.expected, formatted)
+			continue
+		}
+
+		again, err := Source(formatted)
+		if err != nil || again != formatted {
+			t.Errorf("formatting is not idempotent for %q.\nfirst= %q\nsecond=%q", tt.input, formatted, again)
+		}
+
+		// Formatting must keep the meaning of the program
+		if parse(t, formatted).String() != parse(t, tt.input).String() {
+			t.Errorf("formatting changed the program %q: %q", tt.input, formatted)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// Header
+
+// Adds numbers
+let add = fn(a, b) { // params
+  // the sum
+
+  let sum = a+b; // trailing
+  sum
+  // after sum
+}; // end of add
+let h = {
+  "a": 1, // first
+
+  // own line
+  "b": 2 // second
+};
+let empty = fn() {
+  // todo
+};
+let arr = [1,
+  2];
+let none = [
+];
+puts(add(1, 2)) // call
+// final`
+
+	expected := `// Header
+
+// Adds numbers
+let add = fn(a, b) { // params
+  // the sum
+
+  let sum = a + b; // trailing
+  sum;
+  // after sum
+}; // end of add
+let h = {
+  "a": 1, // first
+
+  // own line
+  "b": 2 // second
+};
+let empty = fn() {
+  // todo
+};
+let arr = [
+  1,
+  2
+];
+let none = [];
+puts(add(1, 2)); // call
+// final
+`
+
+	formatted, err := Source(input)
+	if err != nil {
+		t.Fatalf("could not format: %s", err)
+	}
+
+	if formatted != expected {
+		t.Errorf("wrong formatting.\nwant=%q\ngot= %q", expected, formatted)
+	}
+
+	if again, _ := Source(formatted); again != formatted {
+		t.Errorf("formatting is not idempotent.\nfirst= %q\nsecond=%q", formatted, again)
+	}
+
+	if parse(t, formatted).String() != parse(t, input).String() {
+		t.Errorf("formatting changed the program: %q", formatted)
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("let = 5;")
+	if err == nil {
+		t.Fatalf("expected a syntax error")
+	}
+
+	expected := "1:5: expected next token to be IDENT, but got = instead"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parse(t, "let f = fn(a) { a * (a + 1) };")
+
+	expected := "fn(a) {\n  a * (a + 1);\n}"
+	if got := Node(program.Statements[0].(*ast.LetStatement).Value); got != expected {
+		t.Errorf("wrong formatting. want=%q, got=%q", expected, got)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
@@ -1,30 +1,53 @@
 package lexer
 
-import "github.com/lukeomalley/monkey_lang/token"
+import (
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/token"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+	comments     bool // emit comments as tokens rather than skipping them
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewWithComments constructs a lexer that returns comments as COMMENT tokens,
+// for tools that need to keep them
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.comments = true
+	return l
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	for !l.comments && l.ch == '/' && l.peekChar() == '/' {
+		l.readComment()
+		l.skipWhitespace()
+	}
+
+	pos := token.Position{Line: l.line, Column: l.column}
 
 	switch l.ch {
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -46,6 +69,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			tok.Pos = pos
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
@@ -80,20 +109,31 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
 			return tok
 		}
 		tok = newToken(token.ILLEGAL, l.ch)
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -112,12 +152,24 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[initialPosition:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when the digits are followed by a
+// decimal point and more digits
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	initialPosition := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[initialPosition:l.position]
+
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return l.input[initialPosition:l.position], token.INT
+	}
+
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+
+	return l.input[initialPosition:l.position], token.FLOAT
 }
 
 func (l *Lexer) readString() string {
@@ -132,6 +184,16 @@ func (l *Lexer) readString() string {
 	return l.input[position:l.position]
 }
 
+// readComment reads a comment up to the end of the line, without the newline
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return strings.TrimRight(l.input[position:l.position], " \t\r")
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
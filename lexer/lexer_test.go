@@ -34,6 +34,7 @@ func TestNextToken(t *testing.T) {
 
 		[1, 2];
 		{"foo": "bar"}
+		math.abs
 	`
 
 	tests := []struct {
@@ -126,6 +127,9 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "math"},
+		{token.DOT, "."},
+		{token.IDENT, "abs"},
 		{token.EOF, ""},
 	}
 
@@ -146,3 +150,121 @@ func TestNextToken(t *testing.T) {
 	}
 
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x == \"a b\"\n\n[1]"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"==", 2, 5},
+		{"a b", 2, 8},
+		{"[", 4, 1},
+		{"1", 4, 2},
+		{"]", 4, 3},
+		{"", 4, 4},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%d:%d, got=%s",
+				i, tt.expectedLiteral, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := "3.14 10 2.x 7."
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "10"},
+		{token.INT, "2"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// header\nlet x = 10 / 2; // half  \n//\nx"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// header", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "10", 2, 9},
+		{token.SLASH, "/", 2, 12},
+		{token.INT, "2", 2, 14},
+		{token.SEMICOLON, ";", 2, 15},
+		{token.COMMENT, "// half", 2, 17},
+		{token.COMMENT, "//", 3, 1},
+		{token.IDENT, "x", 4, 1},
+		{token.EOF, "", 4, 2},
+	}
+
+	l := NewWithComments(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%d:%d, got=%s",
+				i, tt.expectedLiteral, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+
+	// By default comments are skipped
+	l = New(input)
+	for i, tt := range tests {
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+
+		if tok := l.NextToken(); tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - type wrong without comments. expected=%s, got=%s", i, tt.expectedType, tok.Type)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/evaluator"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/resolve"
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// Rules reported by the linter
+const (
+	SyntaxRule            = "syntax"
+	UnusedVariableRule    = "unused-variable"
+	UnusedParameterRule   = "unused-parameter"
+	ShadowRule            = "shadow"
+	UnreachableRule       = "unreachable"
+	ArityRule             = "arity"
+	ConstantConditionRule = "constant-condition"
+	DuplicateKeyRule      = "duplicate-key"
+)
+
+// Problem is a likely mistake found in a program
+type Problem struct {
+	Pos     token.Position
+	Rule    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Pos, p.Message, p.Rule)
+}
+
+// Source lints Monkey source code against the builtins of the registry. Source
+// that does not parse is reported with its syntax errors only.
+func Source(src string, builtins *object.Registry) []Problem {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if errs := p.SyntaxErrors(); len(errs) != 0 {
+		problems := make([]Problem, len(errs))
+		for i, err := range errs {
+			problems[i] = Problem{Pos: err.Pos, Rule: SyntaxRule, Message: err.Message}
+		}
+		return problems
+	}
+
+	return Program(program, builtins)
+}
+
+// Program lints a parsed program, returning the problems ordered by position
+func Program(program *ast.Program, builtins *object.Registry) []Problem {
+	l := &linter{
+		names:    resolve.New(builtins),
+		builtins: make(map[string]*object.Builtin),
+	}
+
+	for _, b := range builtins.Builtins() {
+		l.builtins[b.Name] = b
+	}
+
+	l.walk(program)
+	l.reportUnused()
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i].Pos, l.problems[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return l.problems
+}
+
+// binding is a name bound by let, struct or a function parameter
+type binding struct {
+	ident     *ast.Identifier
+	parameter bool
+	function  *ast.FunctionLiteral // the value bound by let, when it is a function
+	structure *ast.StructStatement // the declaration, when it names a struct type
+	used      bool
+}
+
+// linter resolves names with symbol tables exactly as the compiler does
+type linter struct {
+	problems []Problem
+
+	names    *resolve.Resolver
+	builtins map[string]*object.Builtin
+	bindings []*binding // every binding in source order
+}
+
+func (l *linter) walk(node ast.Node) {
+	// Statements and expressions that failed to parse are nil
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		l.statements(node.Statements)
+
+	case *ast.BlockStatement:
+		l.statements(node.Statements)
+
+	case *ast.LetStatement:
+		b := &binding{ident: node.Name}
+		l.define(b)
+
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			b.function = fn
+			l.function(fn, b)
+			return
+		}
+		l.walk(node.Value)
+
+	case *ast.StructStatement:
+		l.define(&binding{ident: node.Name, structure: node})
+
+	case *ast.AssignStatement:
+		l.walk(node.Target)
+		l.walk(node.Value)
+
+	case *ast.ReturnStatement:
+		l.walk(node.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		l.walk(node.Expression)
+
+	case *ast.Identifier:
+		if b := l.resolve(node.Value); b != nil {
+			b.used = true
+		}
+
+	case *ast.SelectorExpression:
+		l.walk(node.Left)
+
+	case *ast.FunctionLiteral:
+		l.function(node, nil)
+
+	case *ast.CallExpression:
+		l.checkArity(node)
+		l.walk(node.Function)
+		for _, arg := range node.Arguments {
+			l.walk(arg)
+		}
+
+	case *ast.IfExpression:
+		l.checkCondition(node)
+		l.walk(node.Condition)
+		l.walk(node.Consequence)
+		l.walk(node.Alternative)
+
+	case *ast.HashLiteral:
+		l.checkKeys(node)
+		for _, key := range node.Keys {
+			l.walk(key)
+			l.walk(node.Pairs[key])
+		}
+
+	case *ast.PrefixExpression:
+		l.walk(node.Right)
+
+	case *ast.InfixExpression:
+		l.walk(node.Left)
+		l.walk(node.Right)
+
+	case *ast.IndexExpression:
+		l.walk(node.Left)
+		l.walk(node.Index)
+
+	case *ast.SliceExpression:
+		l.walk(node.Left)
+		l.walk(node.Start)
+		l.walk(node.End)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			l.walk(el)
+		}
+	}
+}
+
+// statements walks a block, reporting the first statement following a return
+func (l *linter) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		l.walk(stmt)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok && i+1 < len(stmts) {
+			l.report(stmts[i+1].Pos(), UnreachableRule, "unreachable code after return")
+
+			// The rest is still resolved so its names count as used
+			for _, rest := range stmts[i+1:] {
+				l.walk(rest)
+			}
+			return
+		}
+	}
+}
+
+// function walks a function literal in its own symbol table, b is the let
+// binding naming it, if any
+func (l *linter) function(fn *ast.FunctionLiteral, b *binding) {
+	// Only a function bound by let refers to itself by name
+	name := ""
+	if b != nil {
+		name = fn.Name
+	}
+
+	l.names.Enter(name, b)
+	defer l.names.Leave()
+
+	for _, param := range fn.Parameters {
+		l.define(&binding{ident: param, parameter: true})
+	}
+
+	l.walk(fn.Body)
+}
+
+// define binds a name in the current table, reporting a binding it shadows
+func (l *linter) define(b *binding) {
+	name := b.ident.Value
+
+	if sym, ok := l.names.Resolve(name); ok {
+		switch {
+		case sym.Scope == compiler.BuiltinScope:
+			l.report(b.ident.Pos(), ShadowRule, fmt.Sprintf("%s shadows the builtin %s", name, name))
+		case l.definedIn(sym) != l.names.Table():
+			if outer := l.lookup(sym); outer != nil {
+				l.report(b.ident.Pos(), ShadowRule, fmt.Sprintf("%s shadows the binding at %s", name, outer.ident.Pos()))
+			}
+		}
+	}
+
+	l.names.Define(name, b)
+	l.bindings = append(l.bindings, b)
+}
+
+// definedIn returns the table a resolved symbol was defined in, nil when it
+// belongs to an enclosing function
+func (l *linter) definedIn(sym compiler.Symbol) *compiler.SymbolTable {
+	switch sym.Scope {
+	case compiler.GlobalScope:
+		return l.names.Root()
+	case compiler.LocalScope:
+		return l.names.Table()
+	default:
+		return nil
+	}
+}
+
+// resolve finds the binding a name refers to in the current table. A function
+// referring to itself does not count as a use of its binding.
+func (l *linter) resolve(name string) *binding {
+	sym, ok := l.names.Resolve(name)
+	if !ok || sym.Scope == compiler.FunctionScope {
+		return nil
+	}
+
+	return l.lookup(sym)
+}
+
+// lookup returns the binding of a symbol resolved in the current table
+func (l *linter) lookup(sym compiler.Symbol) *binding {
+	b, _ := l.names.Lookup(sym).(*binding)
+	return b
+}
+
+func (l *linter) reportUnused() {
+	for _, b := range l.bindings {
+		if b.used || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+
+		if b.parameter {
+			l.report(b.ident.Pos(), UnusedParameterRule, fmt.Sprintf("parameter %s is never used", b.ident.Value))
+		} else {
+			l.report(b.ident.Pos(), UnusedVariableRule, fmt.Sprintf("%s is never used", b.ident.Value))
+		}
+	}
+}
+
+// checkArity reports calls of let bound functions, struct constructors and
+// builtins with a wrong number of arguments
+func (l *linter) checkArity(call *ast.CallExpression) {
+	var name string
+	switch fn := call.Function.(type) {
+	case *ast.Identifier:
+		name = fn.Value
+	case *ast.SelectorExpression:
+		qualified, ok := fn.QualifiedName()
+		if !ok {
+			return
+		}
+		name = qualified
+	default:
+		return
+	}
+
+	sym, ok := l.names.Resolve(name)
+	if !ok {
+		return
+	}
+
+	args := len(call.Arguments)
+	if sym.Scope == compiler.BuiltinScope {
+		b := l.builtins[sym.Name]
+		if args < b.MinArgs || b.MaxArgs != object.Variadic && args > b.MaxArgs {
+			l.report(call.Pos(), ArityRule, fmt.Sprintf("%s expects %s, got %d", name, describeArity(b.MinArgs, b.MaxArgs), args))
+		}
+		return
+	}
+
+	b := l.lookup(sym)
+	if b == nil {
+		return
+	}
+
+	var params int
+	switch {
+	case b.function != nil:
+		params = len(b.function.Parameters)
+	case b.structure != nil:
+		params = len(b.structure.Fields)
+	default:
+		return
+	}
+
+	if args != params {
+		l.report(call.Pos(), ArityRule, fmt.Sprintf("%s expects %s, got %d", name, describeArity(params, params), args))
+	}
+}
+
+// checkCondition reports conditions built only from literals
+func (l *linter) checkCondition(node *ast.IfExpression) {
+	if !isConstant(node.Condition) {
+		return
+	}
+
+	msg := "condition is constant"
+	switch value := evaluator.Eval(node.Condition, object.NewEnvironment()).(type) {
+	case *object.Boolean:
+		msg = fmt.Sprintf("condition is always %t", value.Value)
+	case *object.Null:
+		msg = "condition is always false"
+	case *object.Error:
+	default:
+		msg = "condition is always true"
+	}
+
+	l.report(node.Condition.Pos(), ConstantConditionRule, msg)
+}
+
+func isConstant(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(exp.Right)
+	case *ast.InfixExpression:
+		return isConstant(exp.Left) && isConstant(exp.Right)
+	default:
+		return false
+	}
+}
+
+// checkKeys reports literal keys that appear twice in a hash literal
+func (l *linter) checkKeys(node *ast.HashLiteral) {
+	seen := make(map[string]bool)
+	for _, key := range node.Keys {
+		var id string
+		switch key := key.(type) {
+		case *ast.StringLiteral:
+			id = fmt.Sprintf("%q", key.Value)
+		case *ast.IntegerLiteral:
+			id = fmt.Sprintf("%d", key.Value)
+		case *ast.Boolean:
+			id = fmt.Sprintf("%t", key.Value)
+		default:
+			continue
+		}
+
+		if seen[id] {
+			l.report(key.Pos(), DuplicateKeyRule, fmt.Sprintf("duplicate key %s in hash literal", id))
+		}
+		seen[id] = true
+	}
+}
+
+func (l *linter) report(pos token.Position, rule, msg string) {
+	l.problems = append(l.problems, Problem{Pos: pos, Rule: rule, Message: msg})
+}
+
+func describeArity(min, max int) string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+
+	switch {
+	case max == object.Variadic:
+		return fmt.Sprintf("at least %s", plural(min))
+	case min == max:
+		return plural(min)
+	default:
+		return fmt.Sprintf("%d to %s", min, plural(max))
+	}
+}
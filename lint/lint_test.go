@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x);", nil},
+		{"let x = 1;", []string{"1:5: x is never used (unused-variable)"}},
+		{"let _x = 1; let f = fn(_a) { 1 }; f(2);", nil},
+		{
+			"let f = fn(a, b) { a }; f(1, 2);",
+			[]string{"1:15: parameter b is never used (unused-parameter)"},
+		},
+		{
+			// Recursion alone does not use a function
+			"let f = fn(n) { f(n - 1) };",
+			[]string{"1:5: f is never used (unused-variable)"},
+		},
+		{
+			"let x = 1; let f = fn() { let x = 2; x }; f(); puts(x);",
+			[]string{"1:31: x shadows the binding at 1:5 (shadow)"},
+		},
+		{
+			"let f = fn(a) { fn(a) { a } }; f(1);",
+			[]string{
+				"1:12: parameter a is never used (unused-parameter)",
+				"1:20: a shadows the binding at 1:12 (shadow)",
+			},
+		},
+		{
+			"let len = fn(a) { a }; len(1);",
+			[]string{"1:5: len shadows the builtin len (shadow)"},
+		},
+		{
+			// Redefining a name in the same scope is not shadowing
+			"let x = 1; puts(x); let x = 2; puts(x);",
+			nil,
+		},
+		{
+			"let f = fn() { return 1; puts(2); puts(3); }; f();",
+			[]string{"1:26: unreachable code after return (unreachable)"},
+		},
+		{
+			"let f = fn(a, b) { a + b }; f(1); f(1, 2); f(1, 2, 3);",
+			[]string{
+				"1:30: f expects 2 arguments, got 1 (arity)",
+				"1:45: f expects 2 arguments, got 3 (arity)",
+			},
+		},
+		{
+			`len(); len("a", "b"); puts(); math.abs(1, 2); first([1]);`,
+			[]string{
+				"1:4: len expects 1 argument, got 0 (arity)",
+				"1:11: len expects 1 argument, got 2 (arity)",
+				"1:39: math.abs expects 1 argument, got 2 (arity)",
+			},
+		},
+		{
+			"struct Point { x, y }; struct Unused {}; let p = Point(1); p.x = 2;",
+			[]string{
+				"1:31: Unused is never used (unused-variable)",
+				"1:55: Point expects 2 arguments, got 1 (arity)",
+			},
+		},
+		{
+			"if (true) { 1 }; if (1 > 2) { 1 }; if (!5) { 1 }; if (x) { 1 }",
+			[]string{
+				"1:5: condition is always true (constant-condition)",
+				"1:24: condition is always false (constant-condition)",
+				"1:40: condition is always false (constant-condition)",
+			},
+		},
+		{
+			// Only false and null are falsy
+			`if (0) { 1 }; if ("") { 1 }`,
+			[]string{
+				"1:5: condition is always true (constant-condition)",
+				"1:19: condition is always true (constant-condition)",
+			},
+		},
+		{
+			`{"a": 1, "b": 2, "a": 3, 1: 1, 1: 2, true: 1, x: 1, x: 2}`,
+			[]string{
+				`1:18: duplicate key "a" in hash literal (duplicate-key)`,
+				"1:32: duplicate key 1 in hash literal (duplicate-key)",
+			},
+		},
+		{"let x = ;", []string{"1:9: no prefix parse function for ; found (syntax)"}},
+	}
+
+	for _, tt := range tests {
+		problems := Source(tt.input, object.NewRegistry())
+
+		got := make([]string, len(problems))
+		for i, p := range problems {
+			got[i] = p.String()
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong problems for %q.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDescribeArity(t *testing.T) {
+	tests := []struct {
+		min, max int
+		expected string
+	}{
+		{0, 0, "0 arguments"},
+		{1, 1, "1 argument"},
+		{1, 3, "1 to 3 arguments"},
+		{1, object.Variadic, "at least 1 argument"},
+	}
+
+	for _, tt := range tests {
+		if got := describeArity(tt.min, tt.max); got != tt.expected {
+			t.Errorf("describeArity(%d, %d) wrong. want=%q, got=%q", tt.min, tt.max, tt.expected, got)
+		}
+	}
+}
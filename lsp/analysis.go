@@ -0,0 +1,680 @@
+package lsp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/checker"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/lexer"
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/parser"
+	"github.com/lukeomalley/monkey_lang/resolve"
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// kind describes what a name is bound to
+type kind string
+
+const (
+	kindVariable  kind = "variable"
+	kindFunction  kind = "function"
+	kindParameter kind = "parameter"
+	kindBuiltin   kind = "builtin"
+	kindStruct    kind = "struct"
+)
+
+// definition is a binding of a name by let, struct, a function parameter or a
+// builtin
+type definition struct {
+	name     string
+	kind     kind
+	pos      token.Position // position of the name, invalid for builtins
+	start    token.Position // start of the let statement
+	end      token.Position // end of the let statement, exclusive
+	value    ast.Expression // the value bound by let
+	function string         // name of the function declaring a parameter
+	builtin  *object.Builtin
+	decl     *ast.StructStatement // the declaration of a struct
+	children []*definition        // let bindings within a function value
+}
+
+// occurrence is the declaration or a use of a name
+type occurrence struct {
+	pos  token.Position
+	size int // length of the name in bytes
+	def  *definition
+	decl bool
+}
+
+// scope holds the bindings visible within a function body, between its braces
+type scope struct {
+	parent   *scope
+	start    token.Position
+	end      token.Position
+	defs     []*definition
+	children []*scope
+}
+
+// document is an analyzed source file
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	diagnostics []Diagnostic
+	symbols     []*definition // top level let bindings
+	occurrences []occurrence
+	resolved    map[*ast.Identifier]*definition
+	root        *scope
+	builtins    []*definition
+}
+
+// analyze parses and resolves a document, collecting diagnostics from the
+// parser, the compiler and the type checker
+func analyze(uri, text string, builtins *object.Registry) *document {
+	doc := &document{
+		uri:      uri,
+		text:     text,
+		lines:    strings.Split(text, "\n"),
+		resolved: make(map[*ast.Identifier]*definition),
+		root:     &scope{},
+	}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+
+	r := newResolver(doc, builtins)
+	r.walk(program)
+
+	// Compiling an incomplete program reports bogus errors, so only syntax
+	// errors are shown until it parses
+	if errs := p.SyntaxErrors(); len(errs) != 0 {
+		for _, err := range errs {
+			doc.addDiagnostic(err.Pos, 1, SeverityError, err.Message)
+		}
+		return doc
+	}
+
+	// The compiler and the checker report every error with its position
+	comp := compiler.NewWithBuiltins(builtins)
+	switch err := comp.Compile(program).(type) {
+	case nil:
+	case compiler.ErrorList:
+		for _, e := range err {
+			doc.addDiagnostic(e.Pos, doc.nameSize(e.Pos), SeverityError, e.Message)
+		}
+	default:
+		doc.addDiagnostic(token.Position{Line: 1, Column: 1}, 0, SeverityError, err.Error())
+	}
+
+	if errs, ok := checker.Check(program, builtins).(compiler.ErrorList); ok {
+		for _, e := range errs {
+			doc.addDiagnostic(e.Pos, doc.nameSize(e.Pos), SeverityError, e.Message)
+		}
+	}
+
+	return doc
+}
+
+func (d *document) addDiagnostic(pos token.Position, size int, severity int, msg string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    d.rangeOf(pos, size),
+		Severity: severity,
+		Source:   "monkey",
+		Message:  msg,
+	})
+}
+
+// nameSize returns the length of the possibly qualified name at the position,
+// or 1 for any other token
+func (d *document) nameSize(pos token.Position) int {
+	if pos.Line < 1 || pos.Line > len(d.lines) || pos.Column > len(d.lines[pos.Line-1]) {
+		return 1
+	}
+
+	line := d.lines[pos.Line-1]
+	start := pos.Column - 1
+	if line[start] == '.' || !isNameByte(line[start]) {
+		return 1
+	}
+
+	end := start
+	for end < len(line) && isNameByte(line[end]) {
+		end++
+	}
+	return end - start
+}
+
+func isNameByte(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_' || ch == '.'
+}
+
+// occurrenceAt returns the name at or right after the position
+func (d *document) occurrenceAt(pos token.Position) (occurrence, bool) {
+	for _, occ := range d.occurrences {
+		if occ.pos.Line == pos.Line && occ.pos.Column <= pos.Column && pos.Column <= occ.pos.Column+occ.size {
+			return occ, true
+		}
+	}
+
+	return occurrence{}, false
+}
+
+// visible returns the bindings visible at a position, innermost first. A
+// shadowed binding is left out.
+func (d *document) visible(pos token.Position) []*definition {
+	s := d.root
+	for {
+		inner := s
+		for _, child := range s.children {
+			if !before(pos, child.start) && before(pos, child.end) {
+				inner = child
+				break
+			}
+		}
+
+		if inner == s {
+			break
+		}
+		s = inner
+	}
+
+	seen := make(map[string]bool)
+	defs := []*definition{}
+	for ; s != nil; s = s.parent {
+		for i := len(s.defs) - 1; i >= 0; i-- {
+			def := s.defs[i]
+			if !before(def.pos, pos) || seen[def.name] {
+				continue
+			}
+
+			seen[def.name] = true
+			defs = append(defs, def)
+		}
+	}
+
+	return defs
+}
+
+// =============================================================================
+// Positions
+// =============================================================================
+
+// position converts a source position to an LSP position, counting UTF-16 code units
+func (d *document) position(pos token.Position) Position {
+	line := pos.Line - 1
+	if line < 0 || line >= len(d.lines) {
+		return Position{Line: line}
+	}
+
+	text := d.lines[line]
+	column := pos.Column - 1
+	if column > len(text) {
+		column = len(text)
+	}
+
+	return Position{Line: line, Character: utf16Len(text[:column])}
+}
+
+// sourcePosition converts an LSP position to a source position
+func (d *document) sourcePosition(pos Position) token.Position {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return token.Position{Line: pos.Line + 1, Column: 1}
+	}
+
+	text := d.lines[pos.Line]
+	units, column := 0, 0
+	for column < len(text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[column:])
+		units += utf16Len(string(r))
+		column += size
+	}
+
+	return token.Position{Line: pos.Line + 1, Column: column + 1}
+}
+
+func (d *document) rangeOf(pos token.Position, size int) Range {
+	return Range{
+		Start: d.position(pos),
+		End:   d.position(token.Position{Line: pos.Line, Column: pos.Column + size}),
+	}
+}
+
+// fullRange spans the whole document
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// =============================================================================
+// Resolution
+// =============================================================================
+
+// resolver binds every name of a program to its definition, resolving names
+// with symbol tables exactly as the compiler does
+type resolver struct {
+	doc *document
+
+	names    *resolve.Resolver
+	scope    *scope
+	parent   *definition // function whose body is being resolved, nil at the top level
+	builtins map[string]*definition
+	braces   map[token.Position]token.Position // matching closing brace of each opening brace
+}
+
+func newResolver(doc *document, builtins *object.Registry) *resolver {
+	r := &resolver{
+		doc:      doc,
+		names:    resolve.New(builtins),
+		scope:    doc.root,
+		builtins: make(map[string]*definition),
+		braces:   matchBraces(doc.text),
+	}
+
+	for _, b := range builtins.Builtins() {
+		def := &definition{name: b.Name, kind: kindBuiltin, builtin: b}
+		r.builtins[b.Name] = def
+		doc.builtins = append(doc.builtins, def)
+	}
+
+	return r
+}
+
+func (r *resolver) walk(node ast.Node) {
+	// Statements and expressions that failed to parse are nil
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			r.walk(stmt)
+		}
+
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.walk(stmt)
+		}
+
+	case *ast.LetStatement:
+		if node.Name == nil {
+			return
+		}
+
+		def := &definition{
+			name:  node.Name.Value,
+			kind:  kindVariable,
+			pos:   node.Name.Pos(),
+			start: node.Pos(),
+			value: node.Value,
+		}
+		r.define(def.name, def)
+		r.declare(node.Name, def)
+
+		if r.parent == nil {
+			r.doc.symbols = append(r.doc.symbols, def)
+		} else {
+			r.parent.children = append(r.parent.children, def)
+		}
+
+		fn, ok := node.Value.(*ast.FunctionLiteral)
+		if ok && fn != nil && fn.Body != nil {
+			def.kind = kindFunction
+			def.end = r.closingBrace(fn.Body.Token.Pos)
+			r.function(fn, def)
+			return
+		}
+
+		def.end = token.Position{Line: def.start.Line, Column: len(r.doc.lines[def.start.Line-1]) + 1}
+		r.walk(node.Value)
+
+	case *ast.StructStatement:
+		def := &definition{
+			name:  node.Name.Value,
+			kind:  kindStruct,
+			pos:   node.Name.Pos(),
+			start: node.Pos(),
+			end:   r.closingBrace(node.Brace.Pos),
+			decl:  node,
+		}
+		r.define(def.name, def)
+		r.declare(node.Name, def)
+
+		if r.parent == nil {
+			r.doc.symbols = append(r.doc.symbols, def)
+		} else {
+			r.parent.children = append(r.parent.children, def)
+		}
+
+	case *ast.AssignStatement:
+		r.walk(node.Target)
+		r.walk(node.Value)
+
+	case *ast.ReturnStatement:
+		r.walk(node.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		r.walk(node.Expression)
+
+	case *ast.Identifier:
+		sym, ok := r.names.Resolve(node.Value)
+		if !ok {
+			return
+		}
+
+		r.use(node, r.lookup(sym))
+
+	case *ast.SelectorExpression:
+		name, ok := node.QualifiedName()
+		if !ok {
+			r.walk(node.Left)
+			return
+		}
+
+		// A variable shadows any namespace with the same name
+		left := node.Left.(*ast.Identifier)
+		if sym, ok := r.names.Resolve(left.Value); ok && sym.Scope != compiler.BuiltinScope {
+			r.use(left, r.lookup(sym))
+			return
+		}
+
+		sym, ok := r.names.Resolve(name)
+		if !ok {
+			return
+		}
+
+		def := r.lookup(sym)
+		r.doc.occurrences = append(r.doc.occurrences, occurrence{pos: left.Pos(), size: len(name), def: def})
+
+	case *ast.FunctionLiteral:
+		r.function(node, nil)
+
+	case *ast.PrefixExpression:
+		r.walk(node.Right)
+
+	case *ast.InfixExpression:
+		r.walk(node.Left)
+		r.walk(node.Right)
+
+	case *ast.IfExpression:
+		r.walk(node.Condition)
+		r.walk(node.Consequence)
+		r.walk(node.Alternative)
+
+	case *ast.CallExpression:
+		r.walk(node.Function)
+		for _, arg := range node.Arguments {
+			r.walk(arg)
+		}
+
+	case *ast.IndexExpression:
+		r.walk(node.Left)
+		r.walk(node.Index)
+
+	case *ast.SliceExpression:
+		r.walk(node.Left)
+		r.walk(node.Start)
+		r.walk(node.End)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.walk(el)
+		}
+
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			r.walk(key)
+			r.walk(node.Pairs[key])
+		}
+	}
+}
+
+// function resolves a function literal in its own symbol table, binding is the
+// let statement naming it, if any
+func (r *resolver) function(fn *ast.FunctionLiteral, binding *definition) {
+	if fn.Body == nil {
+		return
+	}
+
+	outerScope, outerParent := r.scope, r.parent
+	defer func() { r.scope, r.parent = outerScope, outerParent }()
+
+	r.scope = &scope{parent: outerScope, start: fn.Body.Token.Pos, end: r.closingBrace(fn.Body.Token.Pos)}
+	outerScope.children = append(outerScope.children, r.scope)
+
+	// Bindings within anonymous functions are not listed as document symbols
+	r.parent = binding
+	if binding == nil {
+		r.parent = &definition{}
+	}
+
+	// Only a function bound by let refers to itself by name
+	name := ""
+	if binding != nil {
+		name = fn.Name
+	}
+
+	r.names.Enter(name, binding)
+	defer r.names.Leave()
+
+	for _, param := range fn.Parameters {
+		def := &definition{name: param.Value, kind: kindParameter, pos: param.Pos(), function: fn.Name}
+		r.define(param.Value, def)
+		r.declare(param, def)
+	}
+
+	r.walk(fn.Body)
+}
+
+// define binds a name of the current table to its definition
+func (r *resolver) define(name string, def *definition) {
+	r.names.Define(name, def)
+	r.scope.defs = append(r.scope.defs, def)
+}
+
+// lookup finds the definition of a symbol resolved in the current table
+func (r *resolver) lookup(sym compiler.Symbol) *definition {
+	if sym.Scope == compiler.BuiltinScope {
+		return r.builtins[sym.Name]
+	}
+
+	def, _ := r.names.Lookup(sym).(*definition)
+	return def
+}
+
+func (r *resolver) declare(ident *ast.Identifier, def *definition) {
+	r.doc.resolved[ident] = def
+	r.doc.occurrences = append(r.doc.occurrences, occurrence{pos: ident.Pos(), size: len(ident.Value), def: def, decl: true})
+}
+
+func (r *resolver) use(ident *ast.Identifier, def *definition) {
+	r.doc.resolved[ident] = def
+	r.doc.occurrences = append(r.doc.occurrences, occurrence{pos: ident.Pos(), size: len(ident.Value), def: def})
+}
+
+// closingBrace returns the position right after the brace closing the one at
+// pos, or the end of the document when it is not closed
+func (r *resolver) closingBrace(pos token.Position) token.Position {
+	if end, ok := r.braces[pos]; ok {
+		return token.Position{Line: end.Line, Column: end.Column + 1}
+	}
+
+	last := len(r.doc.lines)
+	return token.Position{Line: last, Column: len(r.doc.lines[last-1]) + 1}
+}
+
+// matchBraces pairs the braces of the source
+func matchBraces(src string) map[token.Position]token.Position {
+	braces := make(map[token.Position]token.Position)
+	open := []token.Position{}
+
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tok.Pos)
+		case token.RBRACE:
+			if len(open) > 0 {
+				braces[open[len(open)-1]] = tok.Pos
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	return braces
+}
+
+// =============================================================================
+// Descriptions
+// =============================================================================
+
+// inferKind returns the type of the value of an expression when it is evident
+// from the source, or an empty string
+func (d *document) inferKind(exp ast.Expression, depth int) object.ObjectType {
+	if exp == nil || reflect.ValueOf(exp).IsNil() || depth > 16 {
+		return ""
+	}
+
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.FloatLiteral:
+		return object.FLOAT_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+
+	case *ast.Identifier:
+		def := d.resolved[exp]
+		switch {
+		case def == nil:
+			return ""
+		case def.kind == kindBuiltin:
+			return object.BUILTIN_OBJ
+		case def.kind == kindStruct:
+			return object.STRUCT_TYPE_OBJ
+		default:
+			return d.inferKind(def.value, depth+1)
+		}
+
+	case *ast.CallExpression:
+		// Calling a struct type constructs a value of that type
+		if ident, ok := exp.Function.(*ast.Identifier); ok {
+			if def := d.resolved[ident]; def != nil && def.kind == kindStruct {
+				return object.ObjectType(def.name)
+			}
+		}
+
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			return object.BOOLEAN_OBJ
+		}
+		return d.numericKind(d.inferKind(exp.Right, depth+1), object.INTEGER_OBJ)
+
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "==", "!=", "<", ">":
+			return object.BOOLEAN_OBJ
+		}
+
+		left, right := d.inferKind(exp.Left, depth+1), d.inferKind(exp.Right, depth+1)
+		if exp.Operator == "+" && left == object.STRING_OBJ && right == object.STRING_OBJ {
+			return object.STRING_OBJ
+		}
+		return d.numericKind(left, right)
+	}
+
+	return ""
+}
+
+// numericKind returns the type of arithmetic on two numbers, mixing integers
+// and floats gives a float
+func (d *document) numericKind(left, right object.ObjectType) object.ObjectType {
+	isNumber := func(t object.ObjectType) bool { return t == object.INTEGER_OBJ || t == object.FLOAT_OBJ }
+	if !isNumber(left) || !isNumber(right) {
+		return ""
+	}
+
+	if left == object.FLOAT_OBJ || right == object.FLOAT_OBJ {
+		return object.FLOAT_OBJ
+	}
+	return object.INTEGER_OBJ
+}
+
+// signature describes a binding in Monkey syntax
+func (d *document) signature(def *definition) string {
+	switch def.kind {
+	case kindFunction:
+		fn := def.value.(*ast.FunctionLiteral)
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.Value
+		}
+		return fmt.Sprintf("let %s = fn(%s)", def.name, strings.Join(params, ", "))
+
+	case kindParameter:
+		if def.function != "" {
+			return fmt.Sprintf("%s (parameter of %s)", def.name, def.function)
+		}
+		return fmt.Sprintf("%s (parameter)", def.name)
+
+	case kindBuiltin:
+		return fmt.Sprintf("%s (builtin, %s)", def.name, arity(def.builtin))
+
+	case kindStruct:
+		return def.decl.String()
+
+	default:
+		if k := d.inferKind(def.value, 0); k != "" {
+			return fmt.Sprintf("let %s: %s", def.name, k)
+		}
+		return "let " + def.name
+	}
+}
+
+func arity(b *object.Builtin) string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+
+	switch {
+	case b.MaxArgs == object.Variadic && b.MinArgs == 0:
+		return "any number of arguments"
+	case b.MaxArgs == object.Variadic:
+		return fmt.Sprintf("at least %s", plural(b.MinArgs))
+	case b.MinArgs == b.MaxArgs:
+		return plural(b.MinArgs)
+	default:
+		return fmt.Sprintf("%d to %s", b.MinArgs, plural(b.MaxArgs))
+	}
+}
@@ -0,0 +1,345 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lukeomalley/monkey_lang/format"
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+// textDocumentSyncFull asks the client to send the whole document on every change
+const textDocumentSyncFull = 1
+
+// Server implements the Language Server Protocol for Monkey source files
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	builtins *object.Registry
+
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer constructs a server reading requests from in and writing responses
+// and notifications to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		builtins:  object.NewRegistry(),
+		documents: make(map[string]*document),
+	}
+}
+
+// Serve handles requests until the client exits or closes the input
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Serve()
+}
+
+// Serve handles requests until the client exits or closes the input
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, respErr := s.handle(msg)
+
+		// Notifications have no response, even when they fail
+		if msg.ID == nil || msg.Method == "" {
+			continue
+		}
+
+		if err := s.write(&response{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: respErr}); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request or processes a notification
+func (s *Server) handle(msg *Message) (interface{}, *ResponseError) {
+	if !s.initialized && msg.Method != "initialize" {
+		return nil, &ResponseError{Code: codeServerNotInitialized, Message: "the server is not initialized"}
+	}
+
+	if s.shutdown {
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "the server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				DefinitionProvider:         true,
+				ReferencesProvider:         true,
+				HoverProvider:              true,
+				DocumentSymbolProvider:     true,
+				CompletionProvider:         &completionOptions{},
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: serverInfo{Name: "monkey"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+
+		// The whole document is synchronized, so the last change holds its text
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.publishDiagnostics(params.TextDocument.URI, []Diagnostic{})
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		occ, ok := doc.occurrenceAt(doc.sourcePosition(params.Position))
+		if !ok || !occ.def.pos.IsValid() {
+			return nil, nil
+		}
+		return Location{URI: doc.uri, Range: doc.rangeOf(occ.def.pos, len(occ.def.name))}, nil
+
+	case "textDocument/references":
+		var params referenceParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.references(doc, params), nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		occ, ok := doc.occurrenceAt(doc.sourcePosition(params.Position))
+		if !ok {
+			return nil, nil
+		}
+
+		r := doc.rangeOf(occ.pos, occ.size)
+		return hover{
+			Contents: markupContent{Kind: "markdown", Value: "```monkey\n" + doc.signature(occ.def) + "\n```"},
+			Range:    &r,
+		}, nil
+
+	case "textDocument/documentSymbol":
+		var params documentParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.documentSymbols(doc, doc.symbols), nil
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.completions(doc, params.Position), nil
+
+	case "textDocument/formatting":
+		var params documentParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		// A document that does not parse is left as it is
+		formatted, fmtErr := format.Source(doc.text)
+		if fmtErr != nil || formatted == doc.text {
+			return []TextEdit{}, nil
+		}
+		return []TextEdit{{Range: doc.fullRange(), NewText: formatted}}, nil
+
+	default:
+		if strings.HasPrefix(msg.Method, "$/") {
+			return nil, nil
+		}
+		return nil, &ResponseError{Code: codeMethodNotFound, Message: fmt.Sprintf("unsupported method %q", msg.Method)}
+	}
+}
+
+// update analyzes the new text of a document and publishes its diagnostics
+func (s *Server) update(uri, text string) *ResponseError {
+	doc := analyze(uri, text, s.builtins)
+	s.documents[uri] = doc
+
+	diagnostics := doc.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.publishDiagnostics(uri, diagnostics)
+}
+
+func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) *ResponseError {
+	err := s.write(&notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+	if err != nil {
+		return &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// document decodes the parameters of a request on an open document and returns it
+func (s *Server) document(msg *Message, params interface{}, id *textDocumentIdentifier) (*document, *ResponseError) {
+	if err := decodeParams(msg, params); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.documents[id.URI]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown document %s", id.URI)}
+	}
+
+	return doc, nil
+}
+
+func (s *Server) references(doc *document, params referenceParams) []Location {
+	locations := []Location{}
+
+	occ, ok := doc.occurrenceAt(doc.sourcePosition(params.Position))
+	if !ok {
+		return locations
+	}
+
+	for _, other := range doc.occurrences {
+		if other.def != occ.def || (other.decl && !params.Context.IncludeDeclaration) {
+			continue
+		}
+
+		locations = append(locations, Location{URI: doc.uri, Range: doc.rangeOf(other.pos, other.size)})
+	}
+
+	return locations
+}
+
+func (s *Server) documentSymbols(doc *document, defs []*definition) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, def := range defs {
+		symbol := DocumentSymbol{
+			Name:           def.name,
+			Kind:           SymbolKindVariable,
+			Range:          Range{Start: doc.position(def.start), End: doc.position(def.end)},
+			SelectionRange: doc.rangeOf(def.pos, len(def.name)),
+		}
+
+		if def.kind == kindFunction {
+			symbol.Kind = SymbolKindFunction
+			symbol.Detail = doc.signature(def)
+			symbol.Children = s.documentSymbols(doc, def.children)
+		} else if def.kind == kindStruct {
+			symbol.Kind = SymbolKindStruct
+			symbol.Detail = doc.signature(def)
+		} else if k := doc.inferKind(def.value, 0); k != "" {
+			symbol.Detail = string(k)
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+// completions offers the bindings visible at the position, then the builtins
+func (s *Server) completions(doc *document, pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+
+	for _, def := range doc.visible(doc.sourcePosition(pos)) {
+		seen[def.name] = true
+		items = append(items, completionItem(doc, def))
+	}
+
+	for _, def := range doc.builtins {
+		if !seen[def.name] {
+			items = append(items, completionItem(doc, def))
+		}
+	}
+
+	return items
+}
+
+func completionItem(doc *document, def *definition) CompletionItem {
+	item := CompletionItem{Label: def.name, Kind: CompletionKindVariable, Detail: doc.signature(def)}
+	switch def.kind {
+	case kindFunction, kindBuiltin:
+		item.Kind = CompletionKindFunction
+	case kindStruct:
+		item.Kind = CompletionKindStruct
+	}
+
+	return item
+}
+
+// write sends a response or notification
+func (s *Server) write(msg interface{}) error {
+	return WriteMessage(s.out, msg)
+}
+
+func decodeParams(msg *Message, v interface{}) *ResponseError {
+	if len(msg.Params) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params for %s: %s", msg.Method, err)}
+	}
+
+	return nil
+}
@@ -0,0 +1,478 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const uri = "file:///program.mk"
+
+const program = `let total = 10;
+let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(total, 2);
+puts(x);`
+
+type client struct {
+	t        *testing.T
+	in       io.Writer
+	messages chan *Message
+	served   chan error
+	id       int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, in: clientOut, messages: make(chan *Message, 100), served: make(chan error, 1)}
+
+	go func() {
+		c.served <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			msg, err := ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	c.notify("initialized", map[string]interface{}{})
+
+	return c
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if err := WriteMessage(c.in, msg); err != nil {
+		c.t.Fatalf("could not send %s: %s", method, err)
+	}
+}
+
+// request sends a request and decodes the result of its response
+func (c *client) request(method string, params interface{}, result interface{}) *ResponseError {
+	c.t.Helper()
+
+	c.id++
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}
+	if err := WriteMessage(c.in, msg); err != nil {
+		c.t.Fatalf("could not send %s: %s", method, err)
+	}
+
+	for {
+		resp := c.next()
+		if resp.ID == nil || string(*resp.ID) != strconv.Itoa(c.id) {
+			continue
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		if result != nil {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				c.t.Fatalf("invalid %s result: %s", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the diagnostics published for the document
+func (c *client) diagnostics() []Diagnostic {
+	c.t.Helper()
+
+	for {
+		msg := c.next()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatalf("invalid diagnostics: %s", err)
+		}
+		return params.Diagnostics
+	}
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for a message")
+	}
+	return nil
+}
+
+func (c *client) open(text string) []Diagnostic {
+	c.t.Helper()
+
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "monkey", "version": 1, "text": text},
+	})
+	return c.diagnostics()
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{program, []string{}},
+		{"let x = 1;\nlet y = z + x;\nputs(w);", []string{"1:8-1:9 undefined variable: z", "2:5-2:6 undefined variable: w"}},
+		{"let f = fn() { f() };\nlet g = fn() { h };\nlet h = 1;", []string{"1:15-1:16 undefined variable: h"}},
+		{"let x = 1;\nx.y", []string{"1:1-1:2 field access not supported: int"}},
+		{"let = 5;", []string{"0:4-0:5 expected next token to be IDENT, but got = instead", "0:4-0:5 no prefix parse function for = found"}},
+		{"let s = \"é\"; unknown", []string{"0:13-0:20 undefined variable: unknown"}},
+		{"let total = 1;\nmath.ab(totl)", []string{
+			"1:0-1:7 undefined variable: math.ab (did you mean math.abs?)",
+			"1:8-1:12 undefined variable: totl (did you mean total?)",
+		}},
+		{"let x: int = \"a\";\nlen(x) + \"b\"", []string{
+			"0:13-0:14 cannot use string as int in let x",
+			"1:4-1:5 argument 1 to len must be string, array or hash, got int",
+			"1:7-1:8 unsupported operand types for +: int and string",
+		}},
+	}
+
+	for _, tt := range tests {
+		c := newClient(t)
+
+		got := []string{}
+		for _, d := range c.open(tt.input) {
+			got = append(got, jsonRange(d.Range)+" "+d.Message)
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDiagnosticsOnChange(t *testing.T) {
+	c := newClient(t)
+	if diags := c.open("let x = y;"); len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics. want=1, got=%d", len(diags))
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": "let y = 1;\nlet x = y;"}},
+	})
+	if diags := c.diagnostics(); len(diags) != 0 {
+		t.Errorf("diagnostics were not cleared. got=%v", diags)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		definition      string
+		references      []string
+	}{
+		{5, 9, "1:4-1:7", []string{"1:4-1:7", "5:8-5:11"}},       // add
+		{5, 13, "0:4-0:9", []string{"0:4-0:9", "5:12-5:17"}},     // total
+		{2, 13, "1:13-1:14", []string{"1:13-1:14", "2:12-2:13"}}, // a
+		{3, 2, "2:6-2:9", []string{"2:6-2:9", "3:2-3:5"}},        // sum
+		{6, 5, "5:4-5:5", []string{"5:4-5:5", "6:5-6:6"}},        // x, right after the name
+		{6, 0, "", []string{"6:0-6:4"}},                          // puts
+	}
+
+	for _, tt := range tests {
+		var loc *Location
+		if err := c.request("textDocument/definition", at(tt.line, tt.character), &loc); err != nil {
+			t.Fatalf("definition failed: %s", err.Message)
+		}
+
+		got := ""
+		if loc != nil {
+			got = jsonRange(loc.Range)
+		}
+		if got != tt.definition {
+			t.Errorf("wrong definition at %d:%d. want=%q, got=%q", tt.line, tt.character, tt.definition, got)
+		}
+
+		params := at(tt.line, tt.character)
+		params["context"] = map[string]bool{"includeDeclaration": true}
+
+		var locs []Location
+		if err := c.request("textDocument/references", params, &locs); err != nil {
+			t.Fatalf("references failed: %s", err.Message)
+		}
+
+		refs := []string{}
+		for _, l := range locs {
+			refs = append(refs, jsonRange(l.Range))
+		}
+		if strings.Join(refs, " ") != strings.Join(tt.references, " ") {
+			t.Errorf("wrong references at %d:%d. want=%v, got=%v", tt.line, tt.character, tt.references, refs)
+		}
+	}
+}
+
+func TestClosureAndRecursion(t *testing.T) {
+	c := newClient(t)
+	c.open("let outer = fn(n) {\n  let inner = fn() { n + outer(n) };\n  inner\n};")
+
+	tests := []struct {
+		line, character int
+		definition      string
+	}{
+		{1, 21, "0:15-0:16"}, // n, a free variable of inner
+		{1, 25, "0:4-0:9"},   // outer, the function calling itself
+		{2, 2, "1:6-1:11"},   // inner
+	}
+
+	for _, tt := range tests {
+		var loc *Location
+		c.request("textDocument/definition", at(tt.line, tt.character), &loc)
+		if loc == nil || jsonRange(loc.Range) != tt.definition {
+			t.Errorf("wrong definition at %d:%d. want=%q, got=%+v", tt.line, tt.character, tt.definition, loc)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(program + "\nlet f = 1.5 * total;\nlet s = \"a\" + \"b\";")
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 5, "let total: INTEGER"},
+		{5, 9, "let add = fn(a, b)"},
+		{2, 13, "a (parameter of add)"},
+		{6, 1, "puts (builtin, any number of arguments)"},
+		{3, 3, "let sum"},
+		{7, 4, "let f: FLOAT"},
+		{8, 4, "let s: STRING"},
+	}
+
+	for _, tt := range tests {
+		var h *hover
+		c.request("textDocument/hover", at(tt.line, tt.character), &h)
+
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if h == nil || h.Contents.Value != expected {
+			t.Errorf("wrong hover at %d:%d. want=%q, got=%+v", tt.line, tt.character, expected, h)
+		}
+	}
+
+	var h *hover
+	c.request("textDocument/hover", at(0, 12), &h)
+	if h != nil {
+		t.Errorf("unexpected hover on a literal: %+v", h)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	var symbols []DocumentSymbol
+	c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}, &symbols)
+
+	got := []string{}
+	var describe func(prefix string, symbols []DocumentSymbol)
+	describe = func(prefix string, symbols []DocumentSymbol) {
+		for _, s := range symbols {
+			got = append(got, prefix+s.Name+" "+jsonRange(s.Range)+" "+s.Detail)
+			describe(prefix+s.Name+".", s.Children)
+		}
+	}
+	describe("", symbols)
+
+	expected := []string{
+		"total 0:0-0:15 INTEGER",
+		"add 1:0-4:1 let add = fn(a, b)",
+		"add.sum 2:2-2:18 ",
+		"x 5:0-5:22 ",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols.\nwant=%q\ngot= %q", expected, got)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	c := newClient(t)
+	c.open("struct Point { x, y }\nlet p = Point(1, 2);\np.x = 3;")
+
+	hovers := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 8, "struct Point { x, y }"},
+		{1, 4, "let p: Point"},
+		{1, 9, "struct Point { x, y }"},
+	}
+	for _, tt := range hovers {
+		var h *hover
+		c.request("textDocument/hover", at(tt.line, tt.character), &h)
+
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if h == nil || h.Contents.Value != expected {
+			t.Errorf("wrong hover at %d:%d. want=%q, got=%+v", tt.line, tt.character, expected, h)
+		}
+	}
+
+	var loc *Location
+	c.request("textDocument/definition", at(2, 0), &loc)
+	if loc == nil || jsonRange(loc.Range) != "1:4-1:5" {
+		t.Errorf("wrong definition of p. got=%+v", loc)
+	}
+
+	var symbols []DocumentSymbol
+	c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}, &symbols)
+
+	got := []string{}
+	for _, s := range symbols {
+		got = append(got, fmt.Sprintf("%s %d %s %s", s.Name, s.Kind, jsonRange(s.Range), s.Detail))
+	}
+
+	expected := []string{
+		"Point 23 0:0-0:21 struct Point { x, y }",
+		"p 13 1:0-1:20 Point",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols.\nwant=%q\ngot= %q", expected, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		expected        []string
+	}{
+		{3, 2, []string{"sum", "b", "a", "add", "total"}},
+		{0, 0, []string{}},
+		{6, 0, []string{"x", "add", "total"}},
+	}
+
+	for _, tt := range tests {
+		var items []CompletionItem
+		c.request("textDocument/completion", at(tt.line, tt.character), &items)
+
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+
+		// Bindings of the program come first, innermost first, then the builtins
+		n := len(tt.expected)
+		if len(labels) <= n || strings.Join(labels[:n], " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong completions at %d:%d. want=%v first, got=%v", tt.line, tt.character, tt.expected, labels)
+			continue
+		}
+
+		if labels[n] != "len" || !contains(labels[n:], "puts") {
+			t.Errorf("builtins missing at %d:%d. got=%v", tt.line, tt.character, labels[n:])
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.open("let x=1\nputs( x )")
+
+	params := map[string]interface{}{"textDocument": map[string]string{"uri": uri}}
+
+	var edits []TextEdit
+	c.request("textDocument/formatting", params, &edits)
+	if len(edits) != 1 {
+		t.Fatalf("wrong number of edits. want=1, got=%d", len(edits))
+	}
+
+	if jsonRange(edits[0].Range) != "0:0-1:9" || edits[0].NewText != "let x = 1;\nputs(x);\n" {
+		t.Errorf("wrong edit. got=%+v", edits[0])
+	}
+
+	c.open("let = 1")
+	c.request("textDocument/formatting", params, &edits)
+	if len(edits) != 0 {
+		t.Errorf("a document with syntax errors was formatted: %+v", edits)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("expected an unknown document error, got=%+v", err)
+	}
+
+	if err := c.request("workspace/symbol", map[string]string{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected a method not found error, got=%+v", err)
+	}
+
+	if err := c.request("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %s", err.Message)
+	}
+
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeInvalidRequest {
+		t.Errorf("expected requests to fail after shutdown, got=%+v", err)
+	}
+
+	c.notify("exit", nil)
+	select {
+	case err := <-c.served:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server did not exit")
+	}
+}
+
+func jsonRange(r Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
+
+func contains(items []string, item string) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
@@ -1,152 +1,132 @@
 package object
 
-import "fmt"
-
-var Builtins = []struct {
-	Name    string
-	Builtin *Builtin
-}{
-	{
-		Name: "len",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=%d", len(args), 1)
-				}
-
-				switch arg := args[0].(type) {
-				case *Array:
-					return &Integer{Value: int64(len(arg.Elements))}
-
-				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
-
-				default:
-					return newError("argument to `len` not supported. got=%s", args[0].Type())
-				}
-			},
-		},
-	},
-
-	{
-		Name: "puts",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				for _, arg := range args {
-					fmt.Println(arg.Inspect())
-				}
-
-				return nil
-			},
-		},
-	},
-
-	{
-		Name: "first",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d", len(args))
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be an ARRAY. got=%s", args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				if len(arr.Elements) > 0 {
-					return arr.Elements[0]
-				}
-
-				return nil
-			},
-		},
-	},
-
-	{
-
-		Name: "last",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d", len(args))
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `last` must be an ARRAY. got=%s", args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					return arr.Elements[length-1]
-				}
-
-				return nil
-			},
-		},
-	},
-
-	{
-		Name: "rest",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d", len(args))
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be an ARRAY. got=%s", args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					newElements := make([]Object, length-1, length-1)
-					copy(newElements, arr.Elements[1:length])
-					return &Array{Elements: newElements}
-				}
-
-				return nil
-			},
-		},
-	},
-
-	{
-		Name: "push",
-		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 2 {
-					return newError("wrong number of arguments. got=%d. want=2", len(args))
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `push` must be an ARRAY. got=%s", args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-
-				newElements := make([]Object, length+1, length+1)
-				copy(newElements, arr.Elements)
-				newElements[length] = args[1]
-
-				return &Array{Elements: newElements}
-			},
-		},
-	},
-}
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func registerCoreBuiltins(r *Registry) {
+	mustRegister(r.Register("len", 1, func(ctx CallContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Array:
+			return &Integer{Value: int64(len(arg.Elements))}
+
+		case *String:
+			return &Integer{Value: int64(len(arg.Value))}
+
+		default:
+			return newError("argument to `len` not supported. got=%s", args[0].Type())
+		}
+	}))
 
-func GetBuiltinByName(name string) *Builtin {
-	for _, def := range Builtins {
-		if def.Name == name {
-			return def.Builtin
+	mustRegister(r.Register("puts", Variadic, func(ctx CallContext, args ...Object) Object {
+		out := stdout(ctx)
+		for _, arg := range args {
+			fmt.Fprintln(out, arg.Inspect())
 		}
-	}
 
-	return nil
+		return nil
+	}))
+
+	mustRegister(r.Register("first", 1, func(ctx CallContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("argument to `first` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		if len(arr.Elements) > 0 {
+			return arr.Elements[0]
+		}
+
+		return nil
+	}))
+
+	mustRegister(r.Register("last", 1, func(ctx CallContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("argument to `last` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		length := len(arr.Elements)
+		if length > 0 {
+			return arr.Elements[length-1]
+		}
+
+		return nil
+	}))
+
+	mustRegister(r.Register("rest", 1, func(ctx CallContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("argument to `rest` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		length := len(arr.Elements)
+		if length > 0 {
+			newElements := make([]Object, length-1, length-1)
+			copy(newElements, arr.Elements[1:length])
+			return &Array{Elements: newElements}
+		}
+
+		return nil
+	}))
+
+	mustRegister(r.Register("push", 2, func(ctx CallContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("argument to `push` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		length := len(arr.Elements)
+
+		newElements := make([]Object, length+1, length+1)
+		copy(newElements, arr.Elements)
+		newElements[length] = args[1]
+
+		return &Array{Elements: newElements}
+	}))
+
+	mustRegister(r.Register("print", Variadic, func(ctx CallContext, args ...Object) Object {
+		out := stdout(ctx)
+		for _, arg := range args {
+			io.WriteString(out, arg.Inspect())
+		}
+
+		return nil
+	}))
+
+	// eprint writes each argument on its own line like puts, but to stderr
+	mustRegister(r.Register("eprint", Variadic, func(ctx CallContext, args ...Object) Object {
+		out := stderr(ctx)
+		for _, arg := range args {
+			fmt.Fprintln(out, arg.Inspect())
+		}
+
+		return nil
+	}))
 }
 
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
+
+// stdout returns the output writer of the calling interpreter, falling back to
+// os.Stdout when a builtin is invoked without a context
+func stdout(ctx CallContext) io.Writer {
+	if ctx == nil {
+		return os.Stdout
+	}
+
+	return ctx.Stdout()
+}
+
+// stderr returns the error writer of the calling interpreter, falling back to
+// os.Stderr when a builtin is invoked without a context
+func stderr(ctx CallContext) io.Writer {
+	if ctx == nil {
+		return os.Stderr
+	}
+
+	return ctx.Stderr()
+}
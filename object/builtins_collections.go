@@ -0,0 +1,419 @@
+package object
+
+import (
+	"sort"
+	"strings"
+)
+
+func registerCollectionBuiltins(r *Registry) {
+	mustRegister(r.Register("map", 2, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `map` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		elements := make([]Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			result := ctx.Call(args[1], el)
+			if isError(result) {
+				return result
+			}
+			elements[i] = result
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("filter", 2, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `filter` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		elements := []Object{}
+		for _, el := range arr.Elements {
+			result := ctx.Call(args[1], el)
+			if isError(result) {
+				return result
+			}
+
+			if isTruthy(result) {
+				elements = append(elements, el)
+			}
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.RegisterRange("reduce", 2, 3, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `reduce` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		// Without an initial value the first element is used
+		elements := arr.Elements
+		var acc Object
+		if len(args) == 3 {
+			acc = args[2]
+		} else if len(elements) > 0 {
+			acc, elements = elements[0], elements[1:]
+		} else {
+			return newError("`reduce` of empty ARRAY with no initial value")
+		}
+
+		for _, el := range elements {
+			acc = ctx.Call(args[1], acc, el)
+			if isError(acc) {
+				return acc
+			}
+		}
+
+		return acc
+	}))
+
+	mustRegister(r.Register("sort", 1, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `sort` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		elements := make([]Object, len(arr.Elements))
+		copy(elements, arr.Elements)
+
+		keys := make([]Object, len(arr.Elements))
+		copy(keys, arr.Elements)
+
+		if err := sortObjects(elements, keys); err != nil {
+			return err
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("sort_by", 2, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `sort_by` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		// Compute every key once, then sort the elements along with their keys
+		elements := make([]Object, len(arr.Elements))
+		keys := make([]Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			key := ctx.Call(args[1], el)
+			if isError(key) {
+				return key
+			}
+			elements[i] = el
+			keys[i] = key
+		}
+
+		if err := sortObjects(elements, keys); err != nil {
+			return err
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("reverse", 1, func(ctx CallContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Array:
+			length := len(arg.Elements)
+			elements := make([]Object, length)
+			for i, el := range arg.Elements {
+				elements[length-1-i] = el
+			}
+			return &Array{Elements: elements}
+
+		case *String:
+			runes := []rune(arg.Value)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return &String{Value: string(runes)}
+
+		default:
+			return newError("argument to `reverse` must be an ARRAY or STRING. got=%s", args[0].Type())
+		}
+	}))
+
+	mustRegister(r.RegisterRange("range", 1, 3, func(ctx CallContext, args ...Object) Object {
+		bounds := make([]int64, len(args))
+		for i, arg := range args {
+			integer, ok := arg.(*Integer)
+			if !ok {
+				return newError("argument to `range` must be INTEGER. got=%s", arg.Type())
+			}
+			bounds[i] = integer.Value
+		}
+
+		// range(end), range(start, end) or range(start, end, step)
+		start, end, step := int64(0), bounds[0], int64(1)
+		if len(bounds) > 1 {
+			start, end = bounds[0], bounds[1]
+		}
+		if len(bounds) > 2 {
+			step = bounds[2]
+		}
+
+		if step == 0 {
+			return newError("step passed to `range` must not be zero")
+		}
+
+		elements := []Object{}
+		for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+			elements = append(elements, &Integer{Value: i})
+
+			// Stop when the step reaches end, before i + step can overflow
+			if step > 0 && uint64(end)-uint64(i) <= uint64(step) || step < 0 && uint64(i)-uint64(end) <= -uint64(step) {
+				break
+			}
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("zip", 2, func(ctx CallContext, args ...Object) Object {
+		left, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `zip` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		right, ok := args[1].(*Array)
+		if !ok {
+			return newError("argument to `zip` must be an ARRAY. got=%s", args[1].Type())
+		}
+
+		length := len(left.Elements)
+		if len(right.Elements) < length {
+			length = len(right.Elements)
+		}
+
+		elements := make([]Object, length)
+		for i := 0; i < length; i++ {
+			elements[i] = &Array{Elements: []Object{left.Elements[i], right.Elements[i]}}
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("enumerate", 1, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `enumerate` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		elements := make([]Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			elements[i] = &Array{Elements: []Object{&Integer{Value: int64(i)}, el}}
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("contains", 2, func(ctx CallContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *String:
+			substr, ok := args[1].(*String)
+			if !ok {
+				return newError("argument to `contains` must be STRING. got=%s", args[1].Type())
+			}
+			return NativeBoolToBooleanObject(strings.Contains(arg.Value, substr.Value))
+
+		case *Array:
+			for _, el := range arg.Elements {
+				if Equal(el, args[1]) {
+					return TRUE
+				}
+			}
+			return FALSE
+
+		case *Hash:
+			_, ok := arg.Get(args[1])
+			return NativeBoolToBooleanObject(ok)
+
+		default:
+			return newError("argument to `contains` not supported. got=%s", args[0].Type())
+		}
+	}))
+
+	mustRegister(r.Register("keys", 1, func(ctx CallContext, args ...Object) Object {
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to `keys` must be a HASH. got=%s", args[0].Type())
+		}
+
+		elements := make([]Object, hash.Len())
+		for i, pair := range hash.Pairs() {
+			elements[i] = pair.Key
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("values", 1, func(ctx CallContext, args ...Object) Object {
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to `values` must be a HASH. got=%s", args[0].Type())
+		}
+
+		elements := make([]Object, hash.Len())
+		for i, pair := range hash.Pairs() {
+			elements[i] = pair.Value
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("has_key", 2, func(ctx CallContext, args ...Object) Object {
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to `has_key` must be a HASH. got=%s", args[0].Type())
+		}
+
+		if !IsHashable(args[1]) {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+
+		_, ok = hash.Get(args[1])
+		return NativeBoolToBooleanObject(ok)
+	}))
+
+	mustRegister(r.Register("delete", 2, func(ctx CallContext, args ...Object) Object {
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to `delete` must be a HASH. got=%s", args[0].Type())
+		}
+
+		if !IsHashable(args[1]) {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+
+		result := NewHash()
+		for _, pair := range hash.Pairs() {
+			if !Equal(pair.Key, args[1]) {
+				result.Set(pair.Key, pair.Value)
+			}
+		}
+
+		return result
+	}))
+
+	mustRegister(r.RegisterRange("merge", 1, Variadic, func(ctx CallContext, args ...Object) Object {
+		// Later hashes win, keys keep the position of their first appearance
+		result := NewHash()
+		for _, arg := range args {
+			hash, ok := arg.(*Hash)
+			if !ok {
+				return newError("argument to `merge` must be a HASH. got=%s", arg.Type())
+			}
+
+			for _, pair := range hash.Pairs() {
+				result.Set(pair.Key, pair.Value)
+			}
+		}
+
+		return result
+	}))
+
+	mustRegister(r.Register("flatten", 1, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `flatten` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		// Only a single level of nesting is removed
+		elements := []Object{}
+		for _, el := range arr.Elements {
+			if inner, ok := el.(*Array); ok {
+				elements = append(elements, inner.Elements...)
+			} else {
+				elements = append(elements, el)
+			}
+		}
+
+		return &Array{Elements: elements}
+	}))
+
+	mustRegister(r.Register("unique", 1, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `unique` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		// Hashable elements are tracked in a hash, anything else is compared with
+		// every element kept so far
+		seen := NewHash()
+		elements := []Object{}
+		for _, el := range arr.Elements {
+			if IsHashable(el) {
+				if _, ok := seen.Get(el); ok {
+					continue
+				}
+				seen.Set(el, TRUE)
+			} else if containsEqual(elements, el) {
+				continue
+			}
+
+			elements = append(elements, el)
+		}
+
+		return &Array{Elements: elements}
+	}))
+}
+
+// sortObjects stably sorts elements by the corresponding keys, which must all be
+// integers or all be strings
+func sortObjects(elements, keys []Object) *Error {
+	for i := 1; i < len(keys); i++ {
+		if _, ok := Compare(keys[0], keys[i]); !ok {
+			return newError("cannot sort %s and %s", keys[0].Type(), keys[i].Type())
+		}
+	}
+
+	sort.Stable(&keyedSort{elements: elements, keys: keys})
+	return nil
+}
+
+type keyedSort struct {
+	elements []Object
+	keys     []Object
+}
+
+func (s *keyedSort) Len() int { return len(s.keys) }
+
+func (s *keyedSort) Less(i, j int) bool {
+	result, _ := Compare(s.keys[i], s.keys[j])
+	return result < 0
+}
+
+func (s *keyedSort) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.elements[i], s.elements[j] = s.elements[j], s.elements[i]
+}
+
+func containsEqual(elements []Object, obj Object) bool {
+	for _, el := range elements {
+		if Equal(el, obj) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null, nil:
+		return false
+	default:
+		return true
+	}
+}
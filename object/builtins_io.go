@@ -0,0 +1,173 @@
+package object
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+func registerIOBuiltins(r *Registry) {
+	mustRegister(r.Register("read_file", 1, func(ctx CallContext, args ...Object) Object {
+		sandbox, name, err := r.sandboxArgs("read_file", args)
+		if err != nil {
+			return err
+		}
+
+		data, readErr := sandbox.readFile(name)
+		if readErr != nil {
+			return ioError("read", name, readErr)
+		}
+
+		return &String{Value: string(data)}
+	}))
+
+	mustRegister(r.Register("read_lines", 1, func(ctx CallContext, args ...Object) Object {
+		sandbox, name, err := r.sandboxArgs("read_lines", args)
+		if err != nil {
+			return err
+		}
+
+		data, readErr := sandbox.readFile(name)
+		if readErr != nil {
+			return ioError("read", name, readErr)
+		}
+
+		return stringsToArray(splitLines(string(data)))
+	}))
+
+	mustRegister(r.Register("write_file", 2, func(ctx CallContext, args ...Object) Object {
+		sandbox, name, err := r.sandboxArgs("write_file", args[:1])
+		if err != nil {
+			return err
+		}
+
+		content, ok := args[1].(*String)
+		if !ok {
+			return newError("argument to `write_file` must be STRING. got=%s", args[1].Type())
+		}
+
+		if writeErr := sandbox.writeFile(name, []byte(content.Value)); writeErr != nil {
+			return ioError("write", name, writeErr)
+		}
+
+		return nil
+	}))
+
+	mustRegister(r.RegisterRange("list_dir", 0, 1, func(ctx CallContext, args ...Object) Object {
+		// Without an argument the root of the sandbox is listed
+		if len(args) == 0 {
+			args = []Object{&String{Value: "."}}
+		}
+
+		sandbox, name, err := r.sandboxArgs("list_dir", args)
+		if err != nil {
+			return err
+		}
+
+		entries, readErr := sandbox.readDir(name)
+		if readErr != nil {
+			return ioError("list", name, readErr)
+		}
+
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+
+		return stringsToArray(names)
+	}))
+
+	mustRegister(r.Register("stdin", 0, func(ctx CallContext, args ...Object) Object {
+		sandbox, err := r.enabledSandbox()
+		if err != nil {
+			return err
+		}
+
+		in, inErr := sandbox.input()
+		if inErr != nil {
+			return newError("could not read stdin: %s", inErr)
+		}
+
+		data, readErr := io.ReadAll(in)
+		if readErr != nil {
+			return newError("could not read stdin: %s", readErr)
+		}
+
+		return &String{Value: string(data)}
+	}))
+
+	mustRegister(r.Register("read_line", 0, func(ctx CallContext, args ...Object) Object {
+		sandbox, err := r.enabledSandbox()
+		if err != nil {
+			return err
+		}
+
+		in, inErr := sandbox.input()
+		if inErr != nil {
+			return newError("could not read stdin: %s", inErr)
+		}
+
+		// Returns null once the input is exhausted
+		line, readErr := in.ReadString('\n')
+		if readErr == io.EOF && line == "" {
+			return NULL
+		}
+		if readErr != nil && readErr != io.EOF {
+			return newError("could not read stdin: %s", readErr)
+		}
+
+		return &String{Value: strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")}
+	}))
+}
+
+// enabledSandbox returns the sandbox of the registry, or an error when I/O has
+// not been enabled for this interpreter
+func (r *Registry) enabledSandbox() (*Sandbox, *Error) {
+	if r.sandbox == nil || !r.sandbox.Enabled {
+		return nil, newError("I/O is disabled")
+	}
+
+	return r.sandbox, nil
+}
+
+// sandboxArgs returns the enabled sandbox along with the path passed as the
+// first argument of a file builtin
+func (r *Registry) sandboxArgs(name string, args []Object) (*Sandbox, string, *Error) {
+	sandbox, err := r.enabledSandbox()
+	if err != nil {
+		return nil, "", err
+	}
+
+	strs, err := stringArgs(name, args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return sandbox, strs[0], nil
+}
+
+// ioError reports a failed file operation without exposing the sandbox root
+func ioError(op, name string, err error) *Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return newError("could not %s %s: %s", op, name, err)
+}
+
+// splitLines splits text into lines, dropping line terminators and the empty
+// string following a final newline
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
@@ -0,0 +1,134 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// mapWriter writes into the MapFS used as the read layer, so written files can be read back
+type mapWriter fstest.MapFS
+
+func (m mapWriter) WriteFile(name string, data []byte) error {
+	m[name] = &fstest.MapFile{Data: data}
+	return nil
+}
+
+func TestIOBuiltins(t *testing.T) {
+	files := fstest.MapFS{
+		"scripts/config.txt": {Data: []byte("a=1\r\nb=2\n")},
+		"scripts/data/x.txt": {Data: []byte("x")},
+		"scripts/data/y.txt": {Data: []byte("y")},
+		"secret.txt":         {Data: []byte("secret")},
+	}
+
+	r := NewRegistry()
+	r.SetSandbox(&Sandbox{
+		Enabled: true,
+		Root:    "scripts",
+		FS:      files,
+		Writer:  mapWriter(files),
+		Stdin:   strings.NewReader("first\nsecond\nrest\nof input"),
+	})
+
+	str := func(s string) Object { return &String{Value: s} }
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"read_file", []Object{str("config.txt")}, "a=1\r\nb=2\n"},
+		{"read_lines", []Object{str("config.txt")}, "[a=1, b=2]"},
+		{"read_file", []Object{str("data/../config.txt")}, "a=1\r\nb=2\n"},
+		{"read_file", []Object{str("missing.txt")}, "Error: could not read missing.txt: file does not exist"},
+		{"read_file", []Object{str("../secret.txt")}, "Error: could not read ../secret.txt: path escapes the sandbox"},
+		{"read_file", []Object{str("/secret.txt")}, "Error: could not read /secret.txt: path escapes the sandbox"},
+		{"read_file", []Object{&Integer{Value: 1}}, "Error: argument to `read_file` must be STRING. got=INTEGER"},
+		{"list_dir", []Object{str("data")}, "[x.txt, y.txt]"},
+		{"list_dir", []Object{}, "[config.txt, data]"},
+		{"write_file", []Object{str("out.txt"), str("written")}, "null"},
+		{"read_file", []Object{str("out.txt")}, "written"},
+		{"write_file", []Object{str("../out.txt"), str("")}, "Error: could not write ../out.txt: path escapes the sandbox"},
+		{"read_line", []Object{}, "first"},
+		{"read_line", []Object{}, "second"},
+		{"stdin", []Object{}, "rest\nof input"},
+		{"read_line", []Object{}, "null"},
+	}
+
+	for _, tt := range tests {
+		builtin, ok := r.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("builtin %s not registered", tt.name)
+		}
+
+		result := builtin.Fn(nil, tt.args...)
+		if result == nil {
+			result = NULL
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.name, tt.expected, result.Inspect())
+		}
+	}
+
+	if _, ok := files["scripts/out.txt"]; !ok {
+		t.Errorf("write_file did not write below the sandbox root")
+	}
+}
+
+func TestIOBuiltinsDisabled(t *testing.T) {
+	readOnly := &Sandbox{Enabled: true, FS: fstest.MapFS{}}
+
+	tests := []struct {
+		sandbox  *Sandbox
+		name     string
+		args     []Object
+		expected string
+	}{
+		{nil, "read_file", []Object{&String{Value: "a.txt"}}, "Error: I/O is disabled"},
+		{&Sandbox{FS: fstest.MapFS{}}, "list_dir", []Object{}, "Error: I/O is disabled"},
+		{nil, "stdin", []Object{}, "Error: I/O is disabled"},
+		{readOnly, "write_file", []Object{&String{Value: "a.txt"}, &String{Value: ""}}, "Error: could not write a.txt: writing files is not allowed"},
+		{readOnly, "read_line", []Object{}, "Error: could not read stdin: stdin is not available"},
+	}
+
+	for _, tt := range tests {
+		r := NewRegistry()
+		r.SetSandbox(tt.sandbox)
+
+		builtin, _ := r.Lookup(tt.name)
+		result := builtin.Fn(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.name, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestDirSandbox(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	r.SetSandbox(NewDirSandbox(dir))
+
+	read, _ := r.Lookup("read_file")
+	write, _ := r.Lookup("write_file")
+
+	if result := read.Fn(nil, &String{Value: "in.txt"}); result.Inspect() != "hello" {
+		t.Errorf("read_file wrong result. got=%q", result.Inspect())
+	}
+
+	if result := write.Fn(nil, &String{Value: "out.txt"}, &String{Value: "bye"}); result != nil {
+		t.Fatalf("write_file wrong result. got=%q", result.Inspect())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil || string(data) != "bye" {
+		t.Errorf("write_file wrote %q, err=%v", data, err)
+	}
+}
@@ -0,0 +1,227 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func registerJSONBuiltins(r *Registry) {
+	mustRegister(r.Register("json_parse", 1, func(ctx CallContext, args ...Object) Object {
+		str, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `json_parse` must be STRING. got=%s", args[0].Type())
+		}
+
+		obj, err := parseJSON(str.Value)
+		if err != nil {
+			return newError("invalid JSON: %s", err)
+		}
+
+		return obj
+	}))
+
+	mustRegister(r.RegisterRange("json_stringify", 1, 2, func(ctx CallContext, args ...Object) Object {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, args[0]); err != nil {
+			return newError("cannot encode as JSON: %s", err)
+		}
+
+		if len(args) == 1 {
+			return &String{Value: buf.String()}
+		}
+
+		// The indent is either a number of spaces or the string to indent with
+		var indent string
+		switch arg := args[1].(type) {
+		case *Integer:
+			if arg.Value < 0 {
+				return newError("negative indent passed to `json_stringify`: %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *String:
+			indent = arg.Value
+		default:
+			return newError("indent passed to `json_stringify` must be INTEGER or STRING. got=%s", arg.Type())
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+			return newError("cannot encode as JSON: %s", err)
+		}
+
+		return &String{Value: out.String()}
+	}))
+}
+
+// parseJSON decodes a single JSON value, reading it token by token so that the
+// keys of objects keep their order
+func parseJSON(input string) (Object, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+
+	obj, err := readJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+
+	return obj, nil
+}
+
+func readJSONValue(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+
+	case bool:
+		return NativeBoolToBooleanObject(tok), nil
+
+	case string:
+		return &String{Value: tok}, nil
+
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return &Integer{Value: i}, nil
+		}
+
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number out of range: %s", tok)
+		}
+		return &Float{Value: f}, nil
+
+	case json.Delim:
+		if tok == '[' {
+			elements := []Object{}
+			for dec.More() {
+				el, err := readJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, el)
+			}
+
+			// Consume the closing bracket
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+
+			return &Array{Elements: elements}, nil
+		}
+
+		hash := NewHash()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := readJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			hash.Set(&String{Value: key.(string)}, value)
+		}
+
+		// Consume the closing brace
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return hash, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected token %v", tok)
+	}
+}
+
+// writeJSON writes the compact JSON encoding of obj. Hash keys must be strings or
+// integers, which are written as strings.
+func writeJSON(buf *bytes.Buffer, obj Object) error {
+	switch obj := obj.(type) {
+	case *Null:
+		buf.WriteString("null")
+
+	case *Boolean:
+		buf.WriteString(strconv.FormatBool(obj.Value))
+
+	case *Integer:
+		buf.WriteString(strconv.FormatInt(obj.Value, 10))
+
+	case *Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return fmt.Errorf("unsupported value %s", obj.Inspect())
+		}
+		// Inspect keeps a decimal point, so whole floats read back as floats
+		buf.WriteString(obj.Inspect())
+
+	case *String:
+		writeJSONString(buf, obj.Value)
+
+	case *Array:
+		buf.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, el); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case *Hash:
+		buf.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			switch key := pair.Key.(type) {
+			case *String:
+				writeJSONString(buf, key.Value)
+			case *Integer:
+				writeJSONString(buf, strconv.FormatInt(key.Value, 10))
+			default:
+				return fmt.Errorf("unsupported HASH key type %s", pair.Key.Type())
+			}
+
+			buf.WriteByte(':')
+			if err := writeJSON(buf, pair.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	default:
+		return fmt.Errorf("unsupported type %s", obj.Type())
+	}
+
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// Encode terminates each value with a newline
+	buf.Truncate(buf.Len() - 1)
+}
@@ -0,0 +1,50 @@
+package object
+
+import "testing"
+
+func TestJSONBuiltins(t *testing.T) {
+	r := NewRegistry()
+
+	nested := NewHash()
+	nested.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 1}, &Float{Value: 2.5}, NULL}})
+	nested.Set(&String{Value: "a"}, &String{Value: "x<y \"quoted\"\n"})
+
+	intKeys := NewHash()
+	intKeys.Set(&Integer{Value: 1}, TRUE)
+
+	badKeys := NewHash()
+	badKeys.Set(TRUE, TRUE)
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"json_parse", []Object{&String{Value: `{"b": 1, "a": [true, null, 1.5], "c": {}}`}}, `{b: 1, a: [true, null, 1.5], c: {}}`},
+		{"json_parse", []Object{&String{Value: `"line\nbreak \u00e9"`}}, "line\nbreak é"},
+		{"json_parse", []Object{&String{Value: `1e3`}}, "1000.0"},
+		{"json_parse", []Object{&String{Value: `[1, 2`}}, "Error: invalid JSON: unexpected end of JSON input"},
+		{"json_parse", []Object{&String{Value: `{"a" 1}`}}, "Error: invalid JSON: invalid character '1' after object key"},
+		{"json_parse", []Object{&String{Value: `{} {}`}}, "Error: invalid JSON: unexpected data after top-level value"},
+		{"json_parse", []Object{&Integer{Value: 1}}, "Error: argument to `json_parse` must be STRING. got=INTEGER"},
+		{"json_stringify", []Object{nested}, `{"b":[1,2.5,null],"a":"x<y \"quoted\"\n"}`},
+		{"json_stringify", []Object{intKeys}, `{"1":true}`},
+		{"json_stringify", []Object{nested, &Integer{Value: 2}}, "{\n  \"b\": [\n    1,\n    2.5,\n    null\n  ],\n  \"a\": \"x<y \\\"quoted\\\"\\n\"\n}"},
+		{"json_stringify", []Object{intKeys, &String{Value: "\t"}}, "{\n\t\"1\": true\n}"},
+		{"json_stringify", []Object{badKeys}, "Error: cannot encode as JSON: unsupported HASH key type BOOLEAN"},
+		{"json_stringify", []Object{&Builtin{}}, "Error: cannot encode as JSON: unsupported type BUILTIN"},
+		{"json_stringify", []Object{NULL, TRUE}, "Error: indent passed to `json_stringify` must be INTEGER or STRING. got=BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		builtin, ok := r.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("builtin %s not registered", tt.name)
+		}
+
+		result := builtin.Fn(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.name, tt.expected, result.Inspect())
+		}
+	}
+}
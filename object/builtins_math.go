@@ -0,0 +1,202 @@
+package object
+
+import "math"
+
+func registerMathBuiltins(r *Registry) {
+	ns := r.Namespace("math")
+
+	mustRegister(ns.Register("abs", 1, func(ctx CallContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Integer:
+			if arg.Value == math.MinInt64 {
+				return newError("integer overflow in `math.abs`: %d", arg.Value)
+			}
+			if arg.Value < 0 {
+				return &Integer{Value: -arg.Value}
+			}
+			return arg
+
+		case *Float:
+			return &Float{Value: math.Abs(arg.Value)}
+
+		default:
+			return newError("argument to `math.abs` must be INTEGER or FLOAT. got=%s", arg.Type())
+		}
+	}))
+
+	mustRegister(ns.RegisterRange("min", 1, Variadic, func(ctx CallContext, args ...Object) Object {
+		return extremum("math.min", args, -1)
+	}))
+
+	mustRegister(ns.RegisterRange("max", 1, Variadic, func(ctx CallContext, args ...Object) Object {
+		return extremum("math.max", args, 1)
+	}))
+
+	mustRegister(ns.Register("pow", 2, func(ctx CallContext, args ...Object) Object {
+		// Integer powers stay integers, anything else is computed with floats
+		base, baseIsInt := args[0].(*Integer)
+		exp, expIsInt := args[1].(*Integer)
+		if baseIsInt && expIsInt && exp.Value >= 0 {
+			result, ok := intPow(base.Value, exp.Value)
+			if !ok {
+				return newError("integer overflow in `math.pow`: %d ** %d", base.Value, exp.Value)
+			}
+			return &Integer{Value: result}
+		}
+
+		x, err := numberArg("math.pow", args[0])
+		if err != nil {
+			return err
+		}
+
+		y, err := numberArg("math.pow", args[1])
+		if err != nil {
+			return err
+		}
+
+		return &Float{Value: math.Pow(x, y)}
+	}))
+
+	mustRegister(ns.Register("sqrt", 1, func(ctx CallContext, args ...Object) Object {
+		x, err := numberArg("math.sqrt", args[0])
+		if err != nil {
+			return err
+		}
+
+		if x < 0 {
+			return newError("argument to `math.sqrt` must not be negative. got=%s", args[0].Inspect())
+		}
+
+		return &Float{Value: math.Sqrt(x)}
+	}))
+
+	mustRegister(ns.Register("floor", 1, func(ctx CallContext, args ...Object) Object {
+		return roundWith("math.floor", args[0], math.Floor)
+	}))
+
+	mustRegister(ns.Register("ceil", 1, func(ctx CallContext, args ...Object) Object {
+		return roundWith("math.ceil", args[0], math.Ceil)
+	}))
+
+	mustRegister(ns.Register("round", 1, func(ctx CallContext, args ...Object) Object {
+		return roundWith("math.round", args[0], math.Round)
+	}))
+
+	mustRegister(ns.Register("random", 0, func(ctx CallContext, args ...Object) Object {
+		return &Float{Value: r.rand.Float64()}
+	}))
+
+	mustRegister(ns.RegisterRange("random_int", 1, 2, func(ctx CallContext, args ...Object) Object {
+		bounds := make([]int64, len(args))
+		for i, arg := range args {
+			integer, ok := arg.(*Integer)
+			if !ok {
+				return newError("argument to `math.random_int` must be INTEGER. got=%s", arg.Type())
+			}
+			bounds[i] = integer.Value
+		}
+
+		// random_int(n) returns 0 <= i < n, random_int(min, max) returns min <= i < max
+		low, high := int64(0), bounds[0]
+		if len(bounds) == 2 {
+			low, high = bounds[0], bounds[1]
+		}
+
+		if high <= low {
+			return newError("empty range passed to `math.random_int`: %d..%d", low, high)
+		}
+
+		return &Integer{Value: low + r.rand.Int63n(high-low)}
+	}))
+}
+
+// numberArg returns the value of an integer or float argument
+func numberArg(name string, arg Object) (float64, *Error) {
+	f, ok := FloatValue(arg)
+	if !ok {
+		return 0, newError("argument to `%s` must be INTEGER or FLOAT. got=%s", name, arg.Type())
+	}
+
+	return f, nil
+}
+
+// extremum returns the smallest (sign -1) or largest (sign 1) of the arguments,
+// which may also be passed as a single array
+func extremum(name string, args []Object, sign int) Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			if len(arr.Elements) == 0 {
+				return newError("empty ARRAY passed to `%s`", name)
+			}
+			args = arr.Elements
+		}
+	}
+
+	result := args[0]
+	for _, arg := range args {
+		if _, err := numberArg(name, arg); err != nil {
+			return err
+		}
+
+		if cmp, _ := Compare(arg, result); cmp*sign > 0 {
+			result = arg
+		}
+	}
+
+	return result
+}
+
+// roundWith rounds a float to an integer with fn, integers are returned unchanged
+func roundWith(name string, arg Object, fn func(float64) float64) Object {
+	switch arg := arg.(type) {
+	case *Integer:
+		return arg
+
+	case *Float:
+		f := fn(arg.Value)
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return newError("cannot convert %s to INTEGER in `%s`", arg.Inspect(), name)
+		}
+		return &Integer{Value: int64(f)}
+
+	default:
+		return newError("argument to `%s` must be INTEGER or FLOAT. got=%s", name, arg.Type())
+	}
+}
+
+// intPow returns base raised to exp, or false when the result overflows
+func intPow(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = mulInt(result, base); !ok {
+				return 0, false
+			}
+		}
+
+		// The last square is never used
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
+}
+
+// mulInt returns a * b, or false when the product overflows
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	c := a * b
+	if c/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+		return 0, false
+	}
+
+	return c, true
+}
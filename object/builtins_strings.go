@@ -0,0 +1,195 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+func registerStringBuiltins(r *Registry) {
+	mustRegister(r.Register("split", 2, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("split", args)
+		if err != nil {
+			return err
+		}
+
+		return stringsToArray(strings.Split(strs[0], strs[1]))
+	}))
+
+	mustRegister(r.Register("join", 2, func(ctx CallContext, args ...Object) Object {
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("argument to `join` must be an ARRAY. got=%s", args[0].Type())
+		}
+
+		sep, ok := args[1].(*String)
+		if !ok {
+			return newError("argument to `join` must be STRING. got=%s", args[1].Type())
+		}
+
+		elements := make([]string, len(arr.Elements))
+		for i, el := range arr.Elements {
+			str, ok := el.(*String)
+			if !ok {
+				return newError("elements passed to `join` must be STRING. got=%s", el.Type())
+			}
+			elements[i] = str.Value
+		}
+
+		return &String{Value: strings.Join(elements, sep.Value)}
+	}))
+
+	mustRegister(r.RegisterRange("trim", 1, 2, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("trim", args)
+		if err != nil {
+			return err
+		}
+
+		// An optional second argument lists the characters to trim instead of whitespace
+		if len(strs) == 2 {
+			return &String{Value: strings.Trim(strs[0], strs[1])}
+		}
+
+		return &String{Value: strings.TrimSpace(strs[0])}
+	}))
+
+	mustRegister(r.Register("upper", 1, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("upper", args)
+		if err != nil {
+			return err
+		}
+
+		return &String{Value: strings.ToUpper(strs[0])}
+	}))
+
+	mustRegister(r.Register("lower", 1, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("lower", args)
+		if err != nil {
+			return err
+		}
+
+		return &String{Value: strings.ToLower(strs[0])}
+	}))
+
+	mustRegister(r.Register("replace", 3, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("replace", args)
+		if err != nil {
+			return err
+		}
+
+		return &String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+	}))
+
+	mustRegister(r.Register("starts_with", 2, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("starts_with", args)
+		if err != nil {
+			return err
+		}
+
+		return NativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+	}))
+
+	mustRegister(r.Register("ends_with", 2, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("ends_with", args)
+		if err != nil {
+			return err
+		}
+
+		return NativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+	}))
+
+	mustRegister(r.Register("index_of", 2, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("index_of", args)
+		if err != nil {
+			return err
+		}
+
+		return &Integer{Value: int64(strings.Index(strs[0], strs[1]))}
+	}))
+
+	mustRegister(r.Register("repeat", 2, func(ctx CallContext, args ...Object) Object {
+		str, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `repeat` must be STRING. got=%s", args[0].Type())
+		}
+
+		count, ok := args[1].(*Integer)
+		if !ok {
+			return newError("argument to `repeat` must be INTEGER. got=%s", args[1].Type())
+		}
+
+		if count.Value < 0 {
+			return newError("negative count passed to `repeat`: %d", count.Value)
+		}
+
+		return &String{Value: strings.Repeat(str.Value, int(count.Value))}
+	}))
+
+	mustRegister(r.Register("chars", 1, func(ctx CallContext, args ...Object) Object {
+		strs, err := stringArgs("chars", args)
+		if err != nil {
+			return err
+		}
+
+		chars := []string{}
+		for _, ch := range strs[0] {
+			chars = append(chars, string(ch))
+		}
+
+		return stringsToArray(chars)
+	}))
+
+	mustRegister(r.RegisterRange("format", 1, Variadic, func(ctx CallContext, args ...Object) Object {
+		format, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `format` must be STRING. got=%s", args[0].Type())
+		}
+
+		return &String{Value: fmt.Sprintf(format.Value, formatArgs(args[1:])...)}
+	}))
+}
+
+// stringArgs unwraps arguments that must all be strings
+func stringArgs(name string, args []Object) ([]string, *Error) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING. got=%s", name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	return strs, nil
+}
+
+func stringsToArray(strs []string) *Array {
+	elements := make([]Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &String{Value: s}
+	}
+
+	return &Array{Elements: elements}
+}
+
+// formatArgs converts the arguments of `format` to Go values, so that integers,
+// floats, strings and booleans work with the matching verbs. Other objects are formatted
+// with their Inspect output.
+func formatArgs(args []Object) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *Integer:
+			values[i] = arg.Value
+		case *Float:
+			values[i] = arg.Value
+		case *String:
+			values[i] = arg.Value
+		case *Boolean:
+			values[i] = arg.Value
+		default:
+			values[i] = arg.Inspect()
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,116 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+func registerTypeBuiltins(r *Registry) {
+	mustRegister(r.Register("type", 1, func(ctx CallContext, args ...Object) Object {
+		return &String{Value: string(TypeName(args[0]))}
+	}))
+
+	mustRegister(r.Register("str", 1, func(ctx CallContext, args ...Object) Object {
+		if str, ok := args[0].(*String); ok {
+			return str
+		}
+
+		return &String{Value: args[0].Inspect()}
+	}))
+
+	mustRegister(r.Register("int", 1, func(ctx CallContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Integer:
+			return arg
+
+		case *Float:
+			// Floats are truncated towards zero
+			return &Integer{Value: int64(arg.Value)}
+
+		case *Boolean:
+			if arg.Value {
+				return &Integer{Value: 1}
+			}
+			return &Integer{Value: 0}
+
+		case *String:
+			value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+			if err != nil {
+				return newError("could not convert %s to INTEGER", strconv.Quote(arg.Value))
+			}
+			return &Integer{Value: value}
+
+		default:
+			return newError("argument to `int` not supported. got=%s", args[0].Type())
+		}
+	}))
+
+	mustRegister(r.Register("bool", 1, func(ctx CallContext, args ...Object) Object {
+		return NativeBoolToBooleanObject(isTruthy(args[0]))
+	}))
+
+	mustRegister(r.Register("inspect", 1, func(ctx CallContext, args ...Object) Object {
+		return &String{Value: inspectValue(args[0])}
+	}))
+
+	mustRegister(r.Register("arity", 1, func(ctx CallContext, args ...Object) Object {
+		switch fn := args[0].(type) {
+		case *Function:
+			return &Integer{Value: int64(len(fn.Parameters))}
+
+		case *Closure:
+			return &Integer{Value: int64(fn.Fn.NumParameters)}
+
+		case *Builtin:
+			// Builtins accepting a range of arguments report the minimum
+			return &Integer{Value: int64(fn.MinArgs)}
+
+		default:
+			return newError("argument to `arity` must be a FUNCTION. got=%s", args[0].Type())
+		}
+	}))
+
+	mustRegister(r.Register("name", 1, func(ctx CallContext, args ...Object) Object {
+		var name string
+		switch fn := args[0].(type) {
+		case *Function:
+			name = fn.Name
+
+		case *Closure:
+			name = fn.Fn.Name
+
+		case *Builtin:
+			name = fn.Name
+
+		default:
+			return newError("argument to `name` must be a FUNCTION. got=%s", args[0].Type())
+		}
+
+		// Anonymous functions have no name
+		if name == "" {
+			return NULL
+		}
+
+		return &String{Value: name}
+	}))
+}
+
+// TypeName returns the type of an object as seen by scripts. Functions have the
+// same type whether they are evaluated or compiled, and structs are named by
+// their struct type.
+func TypeName(obj Object) ObjectType {
+	switch obj := obj.(type) {
+	case *Closure, *CompiledFunction:
+		return FUNCTION_OBJ
+	case *Struct:
+		return ObjectType(obj.Def.Name)
+	default:
+		return obj.Type()
+	}
+}
+
+// inspectValue formats an object like Inspect, but quotes strings so that they
+// can be told apart from other values
+func inspectValue(obj Object) string {
+	return inspect(obj, true, nil)
+}
@@ -0,0 +1,189 @@
+package object
+
+import "strings"
+
+// Equal reports whether two objects are structurally equal. Numbers, strings,
+// booleans and null compare by value, arrays and hashes compare element by
+// element, and every other object (functions, builtins, errors) compares by
+// identity. Objects of different types are never equal, except that integers
+// and floats compare numerically. Structs that refer back to themselves are
+// equal when their fields are equal on every path through them.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+// structPair is a pair of structs being compared
+type structPair struct {
+	a, b *Struct
+}
+
+// equal compares two objects, assuming the pairs of structs already being
+// compared are equal so that cycles end
+func equal(a, b Object, comparing map[structPair]bool) bool {
+	if a == b {
+		return true
+	}
+
+	if a == nil || b == nil {
+		return false
+	}
+
+	if isMixedNumeric(a, b) {
+		x, _ := FloatValue(a)
+		y, _ := FloatValue(b)
+		return x == y
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+
+	case *Float:
+		return a.Value == b.(*Float).Value
+
+	case *String:
+		return a.Value == b.(*String).Value
+
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+
+	case *Null:
+		return true
+
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], comparing) {
+				return false
+			}
+		}
+
+		return true
+
+	case *Hash:
+		b := b.(*Hash)
+		if a.Len() != b.Len() {
+			return false
+		}
+
+		for _, pair := range a.Pairs() {
+			value, ok := b.Get(pair.Key)
+			if !ok || !equal(pair.Value, value, comparing) {
+				return false
+			}
+		}
+
+		return true
+
+	case *Struct:
+		b := b.(*Struct)
+		if a.Def != b.Def {
+			return false
+		}
+
+		pair := structPair{a, b}
+		if comparing[pair] {
+			return true
+		}
+		if comparing == nil {
+			comparing = make(map[structPair]bool)
+		}
+		comparing[pair] = true
+
+		for i := range a.Fields {
+			if !equal(a.Fields[i], b.Fields[i], comparing) {
+				return false
+			}
+		}
+
+		return true
+
+	default:
+		return false
+	}
+}
+
+// Compare orders two objects of the same type, returning a negative number when
+// a sorts before b, zero when they are equal and a positive number otherwise.
+// Numbers compare numerically and strings lexicographically by byte; ok is
+// false for any other pair of objects.
+func Compare(a, b Object) (result int, ok bool) {
+	if _, isFloat := a.(*Float); isFloat || isMixedNumeric(a, b) {
+		x, _ := FloatValue(a)
+		y, ok := FloatValue(b)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case a.Value < b.Value:
+			return -1, true
+		case a.Value > b.Value:
+			return 1, true
+		default:
+			return 0, true
+		}
+
+	case *String:
+		b, ok := b.(*String)
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(a.Value, b.Value), true
+
+	default:
+		return 0, false
+	}
+}
+
+// FloatValue returns the value of an integer or float as a float64
+func FloatValue(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// isMixedNumeric reports whether one object is an integer and the other a float
+func isMixedNumeric(a, b Object) bool {
+	switch a.(type) {
+	case *Integer:
+		_, ok := b.(*Float)
+		return ok
+	case *Float:
+		_, ok := b.(*Integer)
+		return ok
+	default:
+		return false
+	}
+}
@@ -0,0 +1,579 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The struct tag used to rename or skip (`monkey:"-"`) fields during conversion
+const structTag = "monkey"
+
+var (
+	objectType      = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	callContextType = reflect.TypeOf((*CallContext)(nil)).Elem()
+)
+
+// FromGo converts a Go value into the equivalent Monkey object. Integers, floats,
+// strings, bools, slices, arrays, maps, structs and functions are supported;
+// pointers are followed and nil becomes NULL.
+func FromGo(value interface{}) (Object, error) {
+	return fromValue(reflect.ValueOf(value))
+}
+
+// ToGo stores the Go equivalent of obj in the value pointed to by target
+func ToGo(obj Object, target interface{}) error {
+	return ToGoWithContext(obj, target, nil)
+}
+
+// ToGoWithContext behaves like ToGo, but can also convert functions and closures
+// into Go funcs that call back into the interpreter through ctx
+func ToGoWithContext(obj Object, target interface{}, ctx CallContext) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer. got=%T", target)
+	}
+
+	return toValue(obj, ptr.Elem(), ctx)
+}
+
+// =============================================================================
+// Go -> Monkey
+// =============================================================================
+
+func fromValue(v reflect.Value) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBoolToBooleanObject(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("integer overflow converting %d", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		if err, ok := v.Interface().(error); ok {
+			return &Error{Message: err.Error()}, nil
+		}
+
+		return fromValue(v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL, nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &String{Value: bytesToString(v)}, nil
+		}
+
+		elements := make([]Object, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			el, err := fromValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+
+		return &Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		return fromMap(v)
+
+	case reflect.Struct:
+		return fromStruct(v)
+
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		builtin, err := funcToBuiltin("", v)
+		if err != nil {
+			return nil, err
+		}
+
+		builtin.Fn = checkArity(builtin.MinArgs, builtin.MaxArgs, builtin.Fn)
+		return builtin, nil
+
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a monkey object", v.Type())
+	}
+}
+
+func bytesToString(v reflect.Value) string {
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+
+	return string(b)
+}
+
+func fromMap(v reflect.Value) (Object, error) {
+	hash := NewHash()
+
+	// Sort the keys so conversion errors are reported deterministically
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	for _, k := range keys {
+		key, err := fromValue(k)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := fromValue(v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := hash.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return hash, nil
+}
+
+func fromStruct(v reflect.Value) (Object, error) {
+	hash := NewHash()
+
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		value, err := fromValue(v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", name, err)
+		}
+
+		hash.Set(&String{Value: name}, value)
+	}
+
+	return hash, nil
+}
+
+// fieldName returns the hash key used for a struct field, and false if the field is skipped
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := strings.Split(field.Tag.Get(structTag), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// funcToBuiltin wraps a Go function so that it can be called from Monkey. The
+// function may return nothing, a single value, an error, or a value and an error.
+// A leading CallContext parameter receives the context of the call.
+func funcToBuiltin(name string, fn reflect.Value) (*Builtin, error) {
+	fnType := fn.Type()
+	if err := checkResults(fnType); err != nil {
+		return nil, fmt.Errorf("function %s %s", name, err)
+	}
+
+	wantsContext := fnType.NumIn() > 0 && fnType.In(0) == callContextType
+
+	numParams := fnType.NumIn()
+	if wantsContext {
+		numParams--
+	}
+
+	minArgs := numParams
+	maxArgs := numParams
+	if fnType.IsVariadic() {
+		minArgs--
+		maxArgs = Variadic
+	}
+
+	call := func(ctx CallContext, args ...Object) Object {
+		in := []reflect.Value{}
+		if wantsContext {
+			in = append(in, reflect.ValueOf(&ctx).Elem())
+		}
+
+		for i, arg := range args {
+			var paramType reflect.Type
+			if fnType.IsVariadic() && len(in) >= fnType.NumIn()-1 {
+				paramType = fnType.In(fnType.NumIn() - 1).Elem()
+			} else {
+				paramType = fnType.In(len(in))
+			}
+
+			param := reflect.New(paramType).Elem()
+			if err := toValue(arg, param, ctx); err != nil {
+				return newError("argument %d to `%s`: %s", i+1, name, err)
+			}
+			in = append(in, param)
+		}
+
+		out := fn.Call(in)
+
+		if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return nil
+		}
+
+		result, err := fromValue(out[0])
+		if err != nil {
+			return newError("result of `%s`: %s", name, err)
+		}
+
+		return result
+	}
+
+	return &Builtin{Name: name, MinArgs: minArgs, MaxArgs: maxArgs, Fn: call}, nil
+}
+
+// checkResults verifies that a func type returns nothing, a value, an error, or a value and an error
+func checkResults(fnType reflect.Type) error {
+	switch {
+	case fnType.NumOut() > 2:
+		return fmt.Errorf("returns too many values: %s", fnType)
+	case fnType.NumOut() == 2 && fnType.Out(1) != errorType:
+		return fmt.Errorf("must return an error as its second value: %s", fnType)
+	}
+
+	return nil
+}
+
+// makeCallback builds a Go func of the given type which applies fn through ctx.
+// Failures are returned through a trailing error result if the func type has one.
+func makeCallback(fn Object, fnType reflect.Type, ctx CallContext) reflect.Value {
+	return reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, fnType.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(fnType.Out(i))
+		}
+
+		fail := func(err error) []reflect.Value {
+			if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+				out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			}
+			return out
+		}
+
+		if fnType.IsVariadic() {
+			variadic := in[len(in)-1]
+			in = in[:len(in)-1]
+			for i := 0; i < variadic.Len(); i++ {
+				in = append(in, variadic.Index(i))
+			}
+		}
+
+		args := make([]Object, len(in))
+		for i, v := range in {
+			arg, err := fromValue(v)
+			if err != nil {
+				return fail(err)
+			}
+			args[i] = arg
+		}
+
+		result := ctx.Call(fn, args...)
+		if errObj, ok := result.(*Error); ok {
+			return fail(fmt.Errorf("%s", errObj.Message))
+		}
+
+		if len(out) > 0 && fnType.Out(0) != errorType {
+			value := reflect.New(fnType.Out(0)).Elem()
+			if err := toValue(result, value, ctx); err != nil {
+				return fail(err)
+			}
+			out[0] = value
+		}
+
+		return out
+	})
+}
+
+// =============================================================================
+// Monkey -> Go
+// =============================================================================
+
+func toValue(obj Object, v reflect.Value, ctx CallContext) error {
+	if obj == nil {
+		obj = NULL
+	}
+
+	// Targets such as object.Object or *object.Integer receive the object itself
+	if v.Kind() != reflect.Interface || v.NumMethod() > 0 {
+		if reflect.TypeOf(obj).AssignableTo(v.Type()) {
+			v.Set(reflect.ValueOf(obj))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+
+		native, err := toNative(obj)
+		if err != nil {
+			return err
+		}
+
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+
+	case reflect.Ptr:
+		if obj == NULL {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		ptr := reflect.New(v.Type().Elem())
+		if err := toValue(obj, ptr.Elem(), ctx); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if v.OverflowInt(i.Value) {
+				return fmt.Errorf("integer %d overflows %s", i.Value, v.Type())
+			}
+			v.SetInt(i.Value)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("integer %d overflows %s", i.Value, v.Type())
+			}
+			v.SetUint(uint64(i.Value))
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if f, ok := FloatValue(obj); ok {
+			v.SetFloat(f)
+			return nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			v.SetString(s.Value)
+			return nil
+		}
+
+	case reflect.Slice:
+		if s, ok := obj.(*String); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s.Value))
+			return nil
+		}
+
+		if obj == NULL {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		if arr, ok := obj.(*Array); ok {
+			slice := reflect.MakeSlice(v.Type(), len(arr.Elements), len(arr.Elements))
+			for i, el := range arr.Elements {
+				if err := toValue(el, slice.Index(i), ctx); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+
+	case reflect.Array:
+		if arr, ok := obj.(*Array); ok {
+			if len(arr.Elements) != v.Len() {
+				return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), v.Type())
+			}
+
+			for i, el := range arr.Elements {
+				if err := toValue(el, v.Index(i), ctx); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			return nil
+		}
+
+	case reflect.Map:
+		if obj == NULL {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		if hash, ok := obj.(*Hash); ok {
+			m := reflect.MakeMapWithSize(v.Type(), hash.Len())
+			for _, pair := range hash.Pairs() {
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := toValue(pair.Key, key, ctx); err != nil {
+					return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+				}
+
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := toValue(pair.Value, value, ctx); err != nil {
+					return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+				}
+
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		}
+
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			return toStruct(hash, v, ctx)
+		}
+
+	case reflect.Func:
+		switch obj.(type) {
+		case *Function, *Closure, *Builtin:
+			if ctx == nil {
+				return fmt.Errorf("cannot convert %s to %s without a call context", obj.Type(), v.Type())
+			}
+
+			if err := checkResults(v.Type()); err != nil {
+				return fmt.Errorf("callback %s", err)
+			}
+
+			v.Set(makeCallback(obj, v.Type(), ctx))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+func toStruct(hash *Hash, v reflect.Value, ctx CallContext) error {
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		value, ok := hash.Get(&String{Value: name})
+		if !ok {
+			continue
+		}
+
+		if err := toValue(value, v.Field(i), ctx); err != nil {
+			return fmt.Errorf("field %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// toNative returns the natural Go representation of an object, used when the
+// conversion target is an empty interface
+func toNative(obj Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+
+	case *Float:
+		return obj.Value, nil
+
+	case *String:
+		return obj.Value, nil
+
+	case *Boolean:
+		return obj.Value, nil
+
+	case *Null:
+		return nil, nil
+
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			native, err := toNative(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = native
+		}
+		return elements, nil
+
+	case *Hash:
+		m := make(map[string]interface{}, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert HASH with %s keys to map[string]interface {}", pair.Key.Type())
+			}
+
+			native, err := toNative(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = native
+		}
+		return m, nil
+
+	default:
+		return obj, nil
+	}
+}
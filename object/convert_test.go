@@ -0,0 +1,179 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type point struct {
+	X      int    `monkey:"x"`
+	Y      int    `monkey:"y"`
+	Label  string `monkey:"-"`
+	hidden bool
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{5, "5"},
+		{uint8(7), "7"},
+		{2.0, "2.0"},
+		{float32(1.5), "1.5"},
+		{"monkey", "monkey"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]byte("bytes"), "bytes"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{&point{X: 1, Label: "skipped"}, "{x: 1, y: 0}"},
+		{errors.New("boom"), "Error: boom"},
+		{&Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	for _, input := range []interface{}{make(chan int), map[point]int{{}: 1}} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%T) expected error", input)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	var u uint8
+	var s string
+	var b bool
+	var f float64
+	var ints []int
+	var m map[string]int
+	var p point
+	var ptr *point
+	var obj Object
+	var native interface{}
+
+	hash := NewHash()
+	hash.Set(&String{Value: "x"}, &Integer{Value: 3})
+	hash.Set(&String{Value: "y"}, &Integer{Value: 4})
+
+	tests := []struct {
+		input    Object
+		target   interface{}
+		expected interface{}
+	}{
+		{&Integer{Value: 42}, &i, 42},
+		{&Integer{Value: 255}, &u, uint8(255)},
+		{&String{Value: "monkey"}, &s, "monkey"},
+		{TRUE, &b, true},
+		{&Integer{Value: 2}, &f, 2.0},
+		{&Float{Value: 2.5}, &f, 2.5},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, &ints, []int{1, 2}},
+		{hash, &m, map[string]int{"x": 3, "y": 4}},
+		{hash, &p, point{X: 3, Y: 4}},
+		{hash, &ptr, &point{X: 3, Y: 4}},
+		{NULL, &ptr, (*point)(nil)},
+		{&Integer{Value: 1}, &obj, Object(&Integer{Value: 1})},
+		{&Array{Elements: []Object{&String{Value: "a"}, NULL}}, &native, []interface{}{"a", nil}},
+	}
+
+	for _, tt := range tests {
+		err := ToGo(tt.input, tt.target)
+		if err != nil {
+			t.Errorf("ToGo(%s) returned error: %s", tt.input.Inspect(), err)
+			continue
+		}
+
+		actual := reflect.ValueOf(tt.target).Elem().Interface()
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("ToGo(%s) wrong. want=%#v, got=%#v", tt.input.Inspect(), tt.expected, actual)
+		}
+	}
+
+	errorTests := []struct {
+		input  Object
+		target interface{}
+	}{
+		{&String{Value: "1"}, &i},
+		{&Integer{Value: 256}, &u},
+		{&Integer{Value: -1}, &u},
+		{&Integer{Value: 1}, i},
+	}
+
+	for _, tt := range errorTests {
+		if err := ToGo(tt.input, tt.target); err == nil {
+			t.Errorf("ToGo(%s, %T) expected error", tt.input.Inspect(), tt.target)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	r := NewRegistry()
+
+	err := r.RegisterFunc("times", func(s string, n int) string {
+		out := ""
+		for i := 0; i < n; i++ {
+			out += s
+		}
+		return out
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = r.Namespace("host").RegisterFunc("sum", func(nums ...int) (int, error) {
+		if len(nums) == 0 {
+			return 0, errors.New("nothing to sum")
+		}
+
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := r.RegisterFunc("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error registering function with two results")
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"times", []Object{&String{Value: "ab"}, &Integer{Value: 2}}, "abab"},
+		{"times", []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "Error: argument 1 to `times`: cannot convert INTEGER to string"},
+		{"times", []Object{&String{Value: "ab"}}, "Error: wrong number of arguments. got=1, want=2"},
+		{"host.sum", []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "3"},
+		{"host.sum", []Object{}, "Error: nothing to sum"},
+	}
+
+	for _, tt := range tests {
+		builtin, ok := r.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("builtin %s not registered", tt.name)
+		}
+
+		result := builtin.Fn(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.name, tt.expected, result.Inspect())
+		}
+	}
+}
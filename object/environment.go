@@ -1,19 +1,32 @@
 package object
 
+import (
+	"io"
+	"os"
+)
+
 func NewEnclosedEnvironment(outter *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outter
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outter, builtins: outter.builtins}
 }
 
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithBuiltins(NewRegistry())
+}
+
+// NewEnvironmentWithBuiltins constructs a top level environment that resolves builtins from the given registry
+func NewEnvironmentWithBuiltins(builtins *Registry) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s}
+	return &Environment{store: s, builtins: builtins}
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	builtins *Registry
+	stdout   io.Writer
+	stderr   io.Writer
+	depth    int // number of nested function calls the environment belongs to
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -29,3 +42,62 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// CallDepth returns the number of nested function calls the environment
+// belongs to, zero for a nil or top level environment
+func (e *Environment) CallDepth() int {
+	if e == nil {
+		return 0
+	}
+	return e.depth
+}
+
+// SetCallDepth records the number of nested function calls the environment of
+// a function call belongs to
+func (e *Environment) SetCallDepth(depth int) {
+	e.depth = depth
+}
+
+// SetOutput redirects the output of the printing builtins for every environment
+// enclosed by this top level environment. A nil writer discards the output.
+func (e *Environment) SetOutput(stdout, stderr io.Writer) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	e.stdout, e.stderr = stdout, stderr
+}
+
+// Stdout returns the writer used by puts and print, os.Stdout unless redirected
+func (e *Environment) Stdout() io.Writer {
+	if e.outer != nil {
+		return e.outer.Stdout()
+	}
+
+	if e.stdout == nil {
+		return os.Stdout
+	}
+
+	return e.stdout
+}
+
+// Stderr returns the writer used by eprint, os.Stderr unless redirected
+func (e *Environment) Stderr() io.Writer {
+	if e.outer != nil {
+		return e.outer.Stderr()
+	}
+
+	if e.stderr == nil {
+		return os.Stderr
+	}
+
+	return e.stderr
+}
+
+// Builtins returns the registry of builtin functions available to the environment
+func (e *Environment) Builtins() *Registry {
+	return e.builtins
+}
@@ -2,18 +2,23 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"strconv"
 	"strings"
 
 	"github.com/lukeomalley/monkey_lang/ast"
 	"github.com/lukeomalley/monkey_lang/code"
+	"github.com/lukeomalley/monkey_lang/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ           = "INTEGER"
+	FLOAT_OBJ             = "FLOAT"
 	BOOLEAN_OBJ           = "BOOLEAN"
 	NULL_OBJ              = "NULL"
 	RETURN_VALUE_OBJ      = "RETURN_VALUE"
@@ -24,7 +29,9 @@ const (
 	ARRAY_OBJ             = "ARRAY"
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
-	COLSURE_OBJ           = "COLSURE_OBJ"
+	CLOSURE_OBJ           = "CLOSURE"
+	STRUCT_TYPE_OBJ       = "STRUCT_TYPE"
+	STRUCT_OBJ            = "STRUCT"
 )
 
 type Object interface {
@@ -32,6 +39,23 @@ type Object interface {
 	Inspect() string
 }
 
+// The boolean and null values are singletons shared by both engines, which
+// compare them by reference
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
+// NativeBoolToBooleanObject returns the shared boolean object for a Go bool
+func NativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+
+	return FALSE
+}
+
 // ============================================================================
 // Integer Object
 // ============================================================================
@@ -48,6 +72,26 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// ============================================================================
+// Float Object
+// ============================================================================
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect formats the float so that it is never mistaken for an integer, e.g. 2.0
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+
+	return s + ".0"
+}
+
 // ============================================================================
 // String Object
 // ============================================================================
@@ -116,16 +160,24 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	Pos     token.Position // where the error occurred, if known
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "Error: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("Error: %s: %s", e.Pos, e.Message)
+	}
+
+	return "Error: " + e.Message
+}
 
 // ============================================================================
 // Function Object
 // ============================================================================
 
 type Function struct {
+	Name       string // name of the let binding the function was defined by, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -133,9 +185,14 @@ type Function struct {
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Parameters, f.Body)
+}
+
+// inspectFunction prints a function as its source, the same way for both engines
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -143,7 +200,7 @@ func (f *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("}")
 
 	return out.String()
@@ -153,10 +210,25 @@ func (f *Function) Inspect() string {
 // Builtin Function Object
 // ============================================================================
 
-type BuiltinFunction func(args ...Object) Object
+// CallContext is handed to every builtin and lets it call back into the
+// interpreter that invoked it, e.g. to apply a closure passed as an argument
+type CallContext interface {
+	// Call synchronously applies a function, closure or builtin to the arguments.
+	// Failures are reported as *Error objects.
+	Call(fn Object, args ...Object) Object
+
+	// Stdout and Stderr return the writers used by the printing builtins
+	Stdout() io.Writer
+	Stderr() io.Writer
+}
+
+type BuiltinFunction func(ctx CallContext, args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name    string
+	MinArgs int
+	MaxArgs int // Variadic when the builtin accepts any number of trailing arguments
+	Fn      BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -171,25 +243,14 @@ type Array struct {
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
-	var out bytes.Buffer
-
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
-	return out.String()
-}
+func (ao *Array) Inspect() string  { return inspect(ao, false, nil) }
 
 // ============================================================================
 // Hash Object
 // ============================================================================
 
+// Hashable is implemented by the scalar objects that can be used as hash keys.
+// Arrays of hashable objects are also usable as keys, see HashKeyOf.
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -204,22 +265,231 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values, preserving the order in which keys were inserted.
+// Pairs are indexed by buckets keyed by the HashKey of their key, and keys within
+// a bucket are compared directly, so two keys whose hashes collide never
+// overwrite each other.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int // indexes into pairs
+}
+
+// NewHash constructs an empty hash
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+func (h *Hash) Inspect() string  { return inspect(h, false, nil) }
+
+// Set stores the value under the key. Replacing the value of an existing key
+// keeps its original position.
+func (h *Hash) Set(key, value Object) error {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	bucket := h.buckets[hashKey]
+	for _, i := range bucket {
+		if Equal(h.pairs[i].Key, key) {
+			h.pairs[i].Value = value
+			return nil
+		}
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	h.buckets[hashKey] = append(bucket, len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+
+	return nil
+}
+
+// Get returns the value stored under the key
+func (h *Hash) Get(key Object) (Object, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+
+	for _, i := range h.buckets[hashKey] {
+		if Equal(h.pairs[i].Key, key) {
+			return h.pairs[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Len returns the number of pairs stored in the hash
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns every key/value pair in insertion order. The returned slice is
+// owned by the hash and must not be modified.
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
+}
+
+// HashKeyOf returns the hash key of any object usable as a hash key: integers,
+// strings, booleans and arrays made up of those
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+
+	case *Array:
+		h := fnv.New64a()
+		for _, el := range obj.Elements {
+			key, ok := HashKeyOf(el)
+			if !ok {
+				return HashKey{}, false
+			}
+
+			binary.Write(h, binary.BigEndian, key.Value)
+			h.Write([]byte(key.Type))
+		}
+
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+
+	default:
+		return HashKey{}, false
+	}
+}
+
+// IsHashable reports whether an object can be used as a hash key
+func IsHashable(obj Object) bool {
+	_, ok := HashKeyOf(obj)
+	return ok
+}
+
+// ============================================================================
+// Struct Objects
+// ============================================================================
+
+// StructType is a user-defined record type declared by a struct statement.
+// Calling it constructs a Struct with a value for every field, in order.
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+func (st *StructType) Inspect() string {
+	return fmt.Sprintf("struct %s { %s }", st.Name, strings.Join(st.Fields, ", "))
+}
+
+// FieldIndex returns the position of the named field
+func (st *StructType) FieldIndex(name string) (int, bool) {
+	for i, field := range st.Fields {
+		if field == name {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// New constructs an instance of the struct type from the values of its fields
+func (st *StructType) New(values []Object) (*Struct, error) {
+	if len(values) != len(st.Fields) {
+		return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", len(st.Fields), len(values))
+	}
+
+	fields := make([]Object, len(values))
+	copy(fields, values)
+
+	return &Struct{Def: st, Fields: fields}, nil
+}
+
+// Struct is an instance of a StructType. Structs are mutable and shared by
+// reference, so assigning to a field is seen through every binding.
+type Struct struct {
+	Def    *StructType
+	Fields []Object // indexed like Def.Fields
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string  { return inspect(s, false, nil) }
+
+// Get returns the value of the named field
+func (s *Struct) Get(name string) (Object, error) {
+	i, ok := s.Def.FieldIndex(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %s in %s", name, s.Def.Name)
+	}
+
+	return s.Fields[i], nil
+}
+
+// Set replaces the value of the named field
+func (s *Struct) Set(name string, value Object) error {
+	i, ok := s.Def.FieldIndex(name)
+	if !ok {
+		return fmt.Errorf("unknown field %s in %s", name, s.Def.Name)
+	}
+
+	s.Fields[i] = value
+	return nil
+}
+
+// inspect formats an object, along with the values within arrays, hashes and
+// structs. Strings are quoted when quote is set. A struct met again while its
+// own fields are being formatted is written as <cycle>, as assigning to a field
+// can make a struct contain itself.
+func inspect(obj Object, quote bool, active map[*Struct]bool) string {
+	var out bytes.Buffer
+
+	switch obj := obj.(type) {
+	case *String:
+		if quote {
+			return strconv.Quote(obj.Value)
+		}
+		return obj.Value
+
+	case *Array:
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = inspect(el, quote, active)
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+
+	case *Hash:
+		pairs := make([]string, obj.Len())
+		for i, pair := range obj.Pairs() {
+			pairs[i] = inspect(pair.Key, quote, active) + ": " + inspect(pair.Value, quote, active)
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+
+	case *Struct:
+		if active[obj] {
+			return "<cycle>"
+		}
+		if active == nil {
+			active = make(map[*Struct]bool)
+		}
+		active[obj] = true
+		defer delete(active, obj)
+
+		fields := make([]string, len(obj.Fields))
+		for i, value := range obj.Fields {
+			fields[i] = obj.Def.Fields[i] + ": " + inspect(value, quote, active)
+		}
+
+		out.WriteString(obj.Def.Name)
+		out.WriteString("{")
+		out.WriteString(strings.Join(fields, ", "))
+		out.WriteString("}")
+
+	default:
+		return obj.Inspect()
+	}
 
 	return out.String()
 }
@@ -233,6 +503,11 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	SourceMap     code.SourceMap
+	Name          string               // name of the let binding the function was defined by, if any
+	LocalNames    []string             // names of the parameters and locals, indexed by local index
+	FreeNames     []string             // names of the captured free variables, indexed by free index
+	Literal       *ast.FunctionLiteral // source of the function, nil for bytecode built by hand
 }
 
 // Type returnns the type of the compiled function
@@ -254,8 +529,13 @@ type Closure struct {
 	Free []Object
 }
 
-func (c *Closure) Type() ObjectType { return COLSURE_OBJ }
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
 
+// Inspect prints the source of the function, like Function.Inspect
 func (c *Closure) Inspect() string {
+	if lit := c.Fn.Literal; lit != nil {
+		return inspectFunction(lit.Parameters, lit.Body)
+	}
+
 	return fmt.Sprintf("Closure[%p]", c)
 }
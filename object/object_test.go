@@ -20,3 +20,192 @@ func TestStringHashLKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash key")
 	}
 }
+
+func TestHashCollisions(t *testing.T) {
+	hash := NewHash()
+	one := &String{Value: "one"}
+	two := &String{Value: "two"}
+
+	hash.Set(one, &Integer{Value: 1})
+	hash.Set(two, &Integer{Value: 2})
+
+	// Simulate a collision by sharing a single bucket between both keys
+	oneKey, _ := HashKeyOf(one)
+	twoKey, _ := HashKeyOf(two)
+	bucket := []int{0, 1}
+	hash.buckets[oneKey] = bucket
+	hash.buckets[twoKey] = bucket
+
+	hash.Set(&String{Value: "two"}, &Integer{Value: 3})
+
+	tests := []struct {
+		key      *String
+		expected int64
+	}{
+		{one, 1},
+		{two, 3},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no value for key %s", tt.key.Value)
+			continue
+		}
+
+		if value.(*Integer).Value != tt.expected {
+			t.Errorf("wrong value for %s. want=%d, got=%s", tt.key.Value, tt.expected, value.Inspect())
+		}
+	}
+}
+
+func TestHashSetAndGet(t *testing.T) {
+	hash := NewHash()
+
+	composite := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	sameComposite := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+
+	hash.Set(&Integer{Value: 1}, &String{Value: "integer"})
+	hash.Set(&String{Value: "1"}, &String{Value: "string"})
+	hash.Set(TRUE, &String{Value: "boolean"})
+	hash.Set(composite, &String{Value: "array"})
+	hash.Set(sameComposite, &String{Value: "replaced"})
+
+	if hash.Len() != 4 {
+		t.Fatalf("hash has wrong length. want=4, got=%d", hash.Len())
+	}
+
+	tests := []struct {
+		key      Object
+		expected string
+	}{
+		{&Integer{Value: 1}, "integer"},
+		{&String{Value: "1"}, "string"},
+		{TRUE, "boolean"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, "replaced"},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no value for key %s", tt.key.Inspect())
+			continue
+		}
+
+		if value.Inspect() != tt.expected {
+			t.Errorf("wrong value for key %s. want=%q, got=%q", tt.key.Inspect(), tt.expected, value.Inspect())
+		}
+	}
+
+	if _, ok := hash.Get(&Array{Elements: []Object{&Integer{Value: 1}}}); ok {
+		t.Errorf("found value for missing composite key")
+	}
+
+	unhashable := &Array{Elements: []Object{&Hash{}}}
+	if err := hash.Set(unhashable, NULL); err == nil {
+		t.Errorf("expected error using array of hashes as key")
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"zebra", "apple", "mango", "kiwi"} {
+		hash.Set(&String{Value: key}, &Integer{Value: int64(len(key))})
+	}
+
+	// Updating a key must not move it
+	hash.Set(&String{Value: "zebra"}, &Integer{Value: 0})
+
+	expected := "{zebra: 0, apple: 5, mango: 5, kiwi: 4}"
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("hash.Inspect() wrong. want=%q, got=%q", expected, hash.Inspect())
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+
+	fn := &Builtin{Name: "f"}
+	point := &StructType{Name: "Point", Fields: []string{"x", "y"}}
+	other := &StructType{Name: "Point", Fields: []string{"x", "y"}}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&Float{Value: 1.5}, &Integer{Value: 1}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{NULL, &Null{}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{&Integer{Value: 1}}}, true},
+		{&Array{Elements: []Object{}}, &Array{Elements: []Object{NULL}}, false},
+		{hash(&String{Value: "a"}, TRUE), hash(&String{Value: "a"}, TRUE), true},
+		{hash(&String{Value: "a"}, TRUE), hash(&String{Value: "a"}, FALSE), false},
+		{hash(&String{Value: "a"}, TRUE), hash(&String{Value: "b"}, TRUE), false},
+		{fn, fn, true},
+		{fn, &Builtin{Name: "f"}, false},
+		{&Struct{Def: point, Fields: []Object{&Integer{Value: 1}, NULL}}, &Struct{Def: point, Fields: []Object{&Float{Value: 1}, NULL}}, true},
+		{&Struct{Def: point, Fields: []Object{&Integer{Value: 1}, NULL}}, &Struct{Def: point, Fields: []Object{&Integer{Value: 2}, NULL}}, false},
+		{&Struct{Def: point, Fields: []Object{NULL, NULL}}, &Struct{Def: other, Fields: []Object{NULL, NULL}}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Equal(%s, %s) wrong. want=%t, got=%t", i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestStruct(t *testing.T) {
+	point := &StructType{Name: "Point", Fields: []string{"x", "y"}}
+	if got := point.Inspect(); got != "struct Point { x, y }" {
+		t.Errorf("wrong struct type inspect. got=%q", got)
+	}
+
+	if _, err := point.New([]Object{&Integer{Value: 1}}); err == nil || err.Error() != "wrong number of arguments: want=2, got=1" {
+		t.Fatalf("wrong error for missing field. got=%v", err)
+	}
+
+	p, err := point.New([]Object{&Integer{Value: 1}, &String{Value: "a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := p.Set("x", &Integer{Value: 3}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := p.Inspect(); got != "Point{x: 3, y: a}" {
+		t.Errorf("wrong struct inspect. got=%q", got)
+	}
+
+	// A struct may contain itself once its fields are assigned
+	if err := p.Set("y", &Array{Elements: []Object{p}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := p.Inspect(); got != "Point{x: 3, y: [<cycle>]}" {
+		t.Errorf("wrong inspect of a cycle. got=%q", got)
+	}
+
+	q, _ := point.New([]Object{&Integer{Value: 3}, NULL})
+	q.Fields[1] = &Array{Elements: []Object{q}}
+	if !Equal(p, q) {
+		t.Errorf("cyclic structs with equal fields are not equal")
+	}
+
+	if _, err := p.Get("z"); err == nil || err.Error() != "unknown field z in Point" {
+		t.Errorf("wrong error for unknown field. got=%v", err)
+	}
+	if err := p.Set("z", NULL); err == nil || err.Error() != "unknown field z in Point" {
+		t.Errorf("wrong error for unknown field. got=%v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package object
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Variadic is used as the arity of a builtin that accepts any number of arguments
+const Variadic = -1
+
+// Registry stores the builtin functions available to a single interpreter.
+// The position of a builtin within the registry is the operand used by OpGetBuiltin,
+// so the compiler and the vm executing its bytecode must share the same registry.
+type Registry struct {
+	builtins []*Builtin
+	indexes  map[string]int
+	rand     *rand.Rand // source used by math.random and math.random_int
+	sandbox  *Sandbox   // file and stdin access of the I/O builtins
+}
+
+// NewRegistry constructs a registry containing the core builtin functions and the standard library
+func NewRegistry() *Registry {
+	r := &Registry{
+		indexes: make(map[string]int),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	registerCoreBuiltins(r)
+	registerStringBuiltins(r)
+	registerCollectionBuiltins(r)
+	registerMathBuiltins(r)
+	registerJSONBuiltins(r)
+	registerIOBuiltins(r)
+	registerTypeBuiltins(r)
+
+	return r
+}
+
+// Seed resets the random source used by the math builtins, so that scripts
+// using math.random produce the same results on every run
+func (r *Registry) Seed(seed int64) {
+	r.rand.Seed(seed)
+}
+
+// SetSandbox gives the I/O builtins of this registry access to the sandbox.
+// Until a sandbox is set, or while it is not enabled, they return errors.
+func (r *Registry) SetSandbox(sandbox *Sandbox) {
+	r.sandbox = sandbox
+}
+
+// Register adds a builtin that must be called with exactly arity arguments, or
+// any number of arguments when arity is Variadic
+func (r *Registry) Register(name string, arity int, fn BuiltinFunction) error {
+	if arity == Variadic {
+		return r.RegisterRange(name, 0, Variadic, fn)
+	}
+
+	return r.RegisterRange(name, arity, arity, fn)
+}
+
+// RegisterRange adds a builtin accepting between minArgs and maxArgs arguments.
+// A maxArgs of Variadic removes the upper bound.
+func (r *Registry) RegisterRange(name string, minArgs, maxArgs int, fn BuiltinFunction) error {
+	if !isQualifiedName(name) {
+		return fmt.Errorf("invalid builtin name: %q", name)
+	}
+
+	if minArgs < 0 || (maxArgs != Variadic && maxArgs < minArgs) {
+		return fmt.Errorf("invalid arity for builtin %s: %d..%d", name, minArgs, maxArgs)
+	}
+
+	if _, ok := r.indexes[name]; ok {
+		return fmt.Errorf("builtin %s already registered", name)
+	}
+
+	builtin := &Builtin{
+		Name:    name,
+		MinArgs: minArgs,
+		MaxArgs: maxArgs,
+		Fn:      checkArity(minArgs, maxArgs, fn),
+	}
+
+	r.indexes[name] = len(r.builtins)
+	r.builtins = append(r.builtins, builtin)
+
+	return nil
+}
+
+// RegisterFunc adds a Go function as a builtin, converting its arguments and
+// results with ToGo and FromGo. The arity is taken from the function signature.
+func (r *Registry) RegisterFunc(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("builtin %s is not a function: %T", name, fn)
+	}
+
+	builtin, err := funcToBuiltin(name, v)
+	if err != nil {
+		return err
+	}
+
+	return r.RegisterRange(name, builtin.MinArgs, builtin.MaxArgs, builtin.Fn)
+}
+
+// Namespace returns a handle used to register builtins under a common prefix,
+// e.g. functions registered on Namespace("math") are called as math.name(...)
+func (r *Registry) Namespace(name string) *Namespace {
+	return &Namespace{registry: r, name: name}
+}
+
+// Lookup returns the builtin registered under the given (possibly qualified) name
+func (r *Registry) Lookup(name string) (*Builtin, bool) {
+	index, ok := r.indexes[name]
+	if !ok {
+		return nil, false
+	}
+
+	return r.builtins[index], true
+}
+
+// Get returns the builtin stored at the given index
+func (r *Registry) Get(index int) (*Builtin, bool) {
+	if index < 0 || index >= len(r.builtins) {
+		return nil, false
+	}
+
+	return r.builtins[index], true
+}
+
+// Builtins returns every registered builtin in registration order
+func (r *Registry) Builtins() []*Builtin {
+	return r.builtins
+}
+
+// Namespace groups builtins under a common prefix within a registry
+type Namespace struct {
+	registry *Registry
+	name     string
+}
+
+// Register adds a builtin to the namespace, see Registry.Register
+func (n *Namespace) Register(name string, arity int, fn BuiltinFunction) error {
+	return n.registry.Register(n.name+"."+name, arity, fn)
+}
+
+// RegisterRange adds a builtin to the namespace, see Registry.RegisterRange
+func (n *Namespace) RegisterRange(name string, minArgs, maxArgs int, fn BuiltinFunction) error {
+	return n.registry.RegisterRange(n.name+"."+name, minArgs, maxArgs, fn)
+}
+
+// RegisterFunc adds a Go function to the namespace, see Registry.RegisterFunc
+func (n *Namespace) RegisterFunc(name string, fn interface{}) error {
+	return n.registry.RegisterFunc(n.name+"."+name, fn)
+}
+
+// =============================================================================
+// Helper Functions
+// =============================================================================
+
+func checkArity(minArgs, maxArgs int, fn BuiltinFunction) BuiltinFunction {
+	return func(ctx CallContext, args ...Object) Object {
+		switch {
+		case minArgs == maxArgs && len(args) != minArgs:
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), minArgs)
+		case maxArgs == Variadic && len(args) < minArgs:
+			return newError("wrong number of arguments. got=%d, want at least %d", len(args), minArgs)
+		case len(args) < minArgs || (maxArgs != Variadic && len(args) > maxArgs):
+			return newError("wrong number of arguments. got=%d, want=%d..%d", len(args), minArgs, maxArgs)
+		}
+
+		return fn(ctx, args...)
+	}
+}
+
+func isQualifiedName(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+
+		for _, ch := range part {
+			if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?' || ch == '!') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func mustRegister(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
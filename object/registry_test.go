@@ -0,0 +1,94 @@
+package object
+
+import "testing"
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry()
+	count := len(r.Builtins())
+
+	double := func(ctx CallContext, args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	}
+
+	if err := r.Register("double", 1, double); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := r.Namespace("host").Register("double", 1, double); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := r.Register("double", 1, double); err == nil {
+		t.Errorf("expected error registering duplicate builtin")
+	}
+
+	if err := r.Register("not valid", 1, double); err == nil {
+		t.Errorf("expected error registering invalid name")
+	}
+
+	if len(r.Builtins()) != count+2 {
+		t.Fatalf("wrong number of builtins. want=%d, got=%d", count+2, len(r.Builtins()))
+	}
+
+	builtin, ok := r.Lookup("host.double")
+	if !ok {
+		t.Fatalf("builtin host.double not found")
+	}
+
+	if builtin.Name != "host.double" {
+		t.Errorf("builtin has wrong name. got=%q", builtin.Name)
+	}
+
+	indexed, ok := r.Get(count + 1)
+	if !ok || indexed != builtin {
+		t.Errorf("builtin not stored at index %d", count+1)
+	}
+}
+
+func TestRegistryArity(t *testing.T) {
+	r := NewRegistry()
+	noop := func(ctx CallContext, args ...Object) Object { return nil }
+
+	r.Register("exact", 2, noop)
+	r.RegisterRange("ranged", 1, 3, noop)
+	r.RegisterRange("atLeast", 1, Variadic, noop)
+	r.Register("any", Variadic, noop)
+
+	tests := []struct {
+		name     string
+		numArgs  int
+		expected string
+	}{
+		{"exact", 2, ""},
+		{"exact", 1, "wrong number of arguments. got=1, want=2"},
+		{"ranged", 3, ""},
+		{"ranged", 0, "wrong number of arguments. got=0, want=1..3"},
+		{"ranged", 4, "wrong number of arguments. got=4, want=1..3"},
+		{"atLeast", 5, ""},
+		{"atLeast", 0, "wrong number of arguments. got=0, want at least 1"},
+		{"any", 0, ""},
+	}
+
+	for _, tt := range tests {
+		builtin, _ := r.Lookup(tt.name)
+		args := make([]Object, tt.numArgs)
+
+		result := builtin.Fn(nil, args...)
+		if tt.expected == "" {
+			if result != nil {
+				t.Errorf("%s(%d args) returned %+v", tt.name, tt.numArgs, result)
+			}
+			continue
+		}
+
+		err, ok := result.(*Error)
+		if !ok {
+			t.Errorf("%s(%d args) did not return an error. got=%T", tt.name, tt.numArgs, result)
+			continue
+		}
+
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message. want=%q, got=%q", tt.expected, err.Message)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package object
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WriteFS is the writable layer of a sandbox
+type WriteFS interface {
+	WriteFile(name string, data []byte) error
+}
+
+// Sandbox controls the file and stdin access of the I/O builtins. File names
+// used by scripts are slash separated, relative to Root and may not leave it.
+// A registry without a sandbox, or with one that is not Enabled, rejects all I/O.
+type Sandbox struct {
+	Enabled bool
+	Root    string    // directory within FS and Writer that paths are resolved against
+	FS      fs.FS     // read layer used by read_file, read_lines and list_dir
+	Writer  WriteFS   // write layer used by write_file, writes are rejected when nil
+	Stdin   io.Reader // input used by stdin and read_line, rejected when nil
+
+	stdin *bufio.Reader
+}
+
+// NewDirSandbox constructs an enabled sandbox reading and writing the files
+// below dir on the host filesystem. Like os.DirFS it does not guard against
+// symbolic links pointing outside of dir.
+func NewDirSandbox(dir string) *Sandbox {
+	return &Sandbox{
+		Enabled: true,
+		FS:      os.DirFS(dir),
+		Writer:  dirWriter(dir),
+	}
+}
+
+var errSandboxEscape = errors.New("path escapes the sandbox")
+
+// resolve converts a script supplied name to a path within the sandbox layers
+func (s *Sandbox) resolve(name string) (string, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return "", errSandboxEscape
+	}
+
+	if s.Root == "" {
+		return name, nil
+	}
+
+	return path.Join(s.Root, name), nil
+}
+
+func (s *Sandbox) readFile(name string) ([]byte, error) {
+	if s.FS == nil {
+		return nil, errors.New("reading files is not allowed")
+	}
+
+	p, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.ReadFile(s.FS, p)
+}
+
+func (s *Sandbox) writeFile(name string, data []byte) error {
+	if s.Writer == nil {
+		return errors.New("writing files is not allowed")
+	}
+
+	p, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	return s.Writer.WriteFile(p, data)
+}
+
+func (s *Sandbox) readDir(name string) ([]fs.DirEntry, error) {
+	if s.FS == nil {
+		return nil, errors.New("reading files is not allowed")
+	}
+
+	p, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.ReadDir(s.FS, p)
+}
+
+// input returns the buffered stdin reader shared by stdin and read_line
+func (s *Sandbox) input() (*bufio.Reader, error) {
+	if s.Stdin == nil {
+		return nil, errors.New("stdin is not available")
+	}
+
+	if s.stdin == nil {
+		s.stdin = bufio.NewReader(s.Stdin)
+	}
+
+	return s.stdin, nil
+}
+
+// dirWriter writes files below a host directory
+type dirWriter string
+
+func (d dirWriter) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.WriteFile(filepath.Join(string(d), filepath.FromSlash(name)), data, 0644)
+}
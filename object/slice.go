@@ -0,0 +1,66 @@
+package object
+
+import "fmt"
+
+// Slice returns the elements of an array, or the bytes of a string, from start up
+// to but not including end. Negative bounds count back from the end, bounds out of
+// range are clamped, and a NULL bound defaults to the start or end of the value.
+func Slice(left, start, end Object) (Object, error) {
+	var length int
+
+	switch left := left.(type) {
+	case *Array:
+		length = len(left.Elements)
+	case *String:
+		length = len(left.Value)
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if to < from {
+		to = from
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		// Arrays are immutable, so the slice can share the underlying elements
+		return &Array{Elements: left.Elements[from:to:to]}, nil
+	default:
+		return &String{Value: left.(*String).Value[from:to]}, nil
+	}
+}
+
+func sliceBound(bound Object, def, length int) (int, error) {
+	switch bound := bound.(type) {
+	case nil, *Null:
+		return def, nil
+
+	case *Integer:
+		i := bound.Value
+		if i < 0 {
+			i += int64(length)
+		}
+
+		switch {
+		case i < 0:
+			return 0, nil
+		case i > int64(length):
+			return length, nil
+		default:
+			return int(i), nil
+		}
+
+	default:
+		return 0, fmt.Errorf("slice index must be INTEGER. got=%s", bound.Type())
+	}
+}
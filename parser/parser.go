@@ -33,6 +33,7 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -56,11 +57,22 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// SyntaxError is a parser error at a position in the source
+type SyntaxError struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
 type Parser struct {
-	l         *lexer.Lexer
-	errors    []string
-	curToken  token.Token
-	peekToken token.Token
+	l            *lexer.Lexer
+	errors       []string
+	syntaxErrors []SyntaxError
+	curToken     token.Token
+	peekToken    token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -72,6 +84,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -94,6 +107,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseSelectorExpression)
 
 	// Read two tokens so that curToken and peekToken are set
 	p.nextToken()
@@ -106,6 +120,16 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// SyntaxErrors returns the errors with their positions, in the order of Errors
+func (p *Parser) SyntaxErrors() []SyntaxError {
+	return p.syntaxErrors
+}
+
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, msg)
+	p.syntaxErrors = append(p.syntaxErrors, SyntaxError{Pos: pos, Message: msg})
+}
+
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
@@ -137,6 +161,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -151,6 +177,12 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		if stmt.Name.Type = p.parseAnnotation(); stmt.Name.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -163,7 +195,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		fl.Name = stmt.Name.Value
 	}
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -177,18 +209,95 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+// parseStructStatement parses a struct declaration. Fields are separated by
+// commas and may be annotated like parameters.
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Brace = p.curToken
+	stmt.Fields = []*ast.Identifier{}
+	seen := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		field := p.parseParameter()
+		if field == nil {
+			return nil
+		}
+
+		if seen[field.Value] {
+			p.addError(field.Pos(), fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value))
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.ASSIGN) {
+		return p.parseAssignStatement(stmt.Token, stmt.Expression)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseAssignStatement parses the value assigned to target, which must select
+// the field of a struct
+func (p *Parser) parseAssignStatement(start token.Token, target ast.Expression) ast.Statement {
+	p.nextToken()
+
+	selector, ok := target.(*ast.SelectorExpression)
+	if !ok {
+		if target != nil {
+			p.addError(p.curToken.Pos, fmt.Sprintf("cannot assign to %s", target))
+		}
+		return nil
+	}
+
+	stmt := &ast.AssignStatement{Token: start, Target: selector}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -224,7 +333,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, msg)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken.Pos, msg)
 		return nil
 	}
 
@@ -341,6 +464,15 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		if lit.ReturnType = p.parseAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -362,13 +494,19 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := p.parseParameter()
+	if ident == nil {
+		return nil
+	}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident := p.parseParameter()
+		if ident == nil {
+			return nil
+		}
 		identifiers = append(identifiers, ident)
 	}
 
@@ -380,6 +518,85 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 }
 
+// parseParameter parses a parameter name and its optional annotation
+func (p *Parser) parseParameter() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COLON) {
+		if ident.Type = p.parseAnnotation(); ident.Type == nil {
+			return nil
+		}
+	}
+
+	return ident
+}
+
+// parseAnnotation parses the type following the colon in the peek token
+func (p *Parser) parseAnnotation() ast.TypeExpression {
+	p.nextToken()
+	p.nextToken()
+	return p.parseType()
+}
+
+// parseType parses the type starting at the current token
+func (p *Parser) parseType() ast.TypeExpression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+
+	case token.LBRACKET:
+		array := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if array.Element = p.parseType(); array.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return array
+
+	case token.LBRACE:
+		hash := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if hash.Key = p.parseType(); hash.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if hash.Value = p.parseType(); hash.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return hash
+
+	case token.FUNCTION:
+		fn := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeExpression{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			fn.Parameters = append(fn.Parameters, param)
+
+			if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+
+		if p.peekTokenIs(token.COLON) {
+			if fn.Return = p.parseAnnotation(); fn.Return == nil {
+				return nil
+			}
+		}
+		return fn
+
+	default:
+		p.addError(p.curToken.Pos, fmt.Sprintf("expected a type, but got %s instead", p.curToken.Type))
+		return nil
+	}
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -421,9 +638,36 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
+	// A colon following the '[' is a slice without a start
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
+
 	p.nextToken()
 	exp.Index = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseSliceExpression parses the remainder of left[start:end] with the current token on the colon
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -431,6 +675,18 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Field = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -446,6 +702,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -483,12 +740,12 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, but got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, msg)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -171,6 +171,26 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %v, got=%v", 2.5, literal.Value)
+	}
+	if literal.TokenLiteral() != "2.5" {
+		t.Errorf("literal.TokenLiteral not %s, got=%s", "2.5", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -645,6 +665,82 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart interface{}
+		expectedEnd   interface{}
+		expected      string
+	}{
+		{"arr[1:2]", 1, 2, "(arr[1:2])"},
+		{"arr[:n]", nil, "n", "(arr[:n])"},
+		{"arr[1:]", 1, nil, "(arr[1:])"},
+		{"arr[:]", nil, nil, "(arr[:])"},
+		{"arr[-2:]", nil, nil, "(arr[(-2):])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, sliceExp.Left, "arr") {
+			return
+		}
+
+		if tt.expectedStart != nil && !testLiteralExpression(t, sliceExp.Start, tt.expectedStart) {
+			return
+		}
+
+		if tt.expectedEnd != nil && !testLiteralExpression(t, sliceExp.End, tt.expectedEnd) {
+			return
+		}
+
+		if sliceExp.String() != tt.expected {
+			t.Errorf("sliceExp.String() wrong. expected=%q, got=%q", tt.expected, sliceExp.String())
+		}
+	}
+}
+
+func TestParsingSelectorExpressions(t *testing.T) {
+	input := "math.abs(1)"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	selector, ok := call.Function.(*ast.SelectorExpression)
+	if !ok {
+		t.Fatalf("call.Function not *ast.SelectorExpression. got=%T", call.Function)
+	}
+
+	if !testIdentifier(t, selector.Left, "math") {
+		return
+	}
+
+	if !testIdentifier(t, selector.Field, "abs") {
+		return
+	}
+
+	if name, ok := selector.QualifiedName(); !ok || name != "math.abs" {
+		t.Errorf("selector.QualifiedName() wrong. got=%q", name)
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -845,6 +941,145 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestOptionalSemicolons(t *testing.T) {
+	input := `let x = 5
+return x
+let y = x`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "let x = 5;return x;let y = x;"
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"fn(a: string, b): int { a }", "fn(a: string, b): int a"},
+		{"let f: fn(int, fn(): bool): any = fn(n) { n }", "let f: fn(int, fn(): bool): any = fn<f>(n)n;"},
+		{"fn(f: fn(int)): {int: int} { {} }", "fn(f: fn(int)): {int: int} {}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	input := "let x: int = 1; let y: = 2; fn(a: [int) {}"
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"1:24: expected a type, but got = instead",
+		"1:39: expected next token to be ], but got ) instead",
+		"1:39: no prefix parse function for ) found",
+	}
+	if len(p.SyntaxErrors()) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%q, got=%v", expected, p.SyntaxErrors())
+	}
+	for i, err := range p.SyntaxErrors() {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong errors. want=%q, got=%v", expected, p.SyntaxErrors())
+			break
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Empty {}; struct User { name: string, tags: [string], }", "struct Empty {  }struct User { name: string, tags: [string] }"},
+		{"let p = Point(1, 2); p.x = p.y + 1;", "let p = Point(1, 2);(p.x) = ((p.y) + 1);"},
+		{"a.b.c = fn() { 1 }", "((a.b).c) = fn()1;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("struct Point { x: int, y }")).ParseProgram()
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.StructStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "Point" || len(stmt.Fields) != 2 || stmt.Fields[0].Type.String() != "int" || stmt.Fields[1].Type != nil {
+		t.Errorf("wrong struct statement. got=%s", stmt)
+	}
+
+	input := "struct P { x, x }; struct Q { x y }; 1 = 2"
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"1:15: duplicate field x in struct P",
+		"1:33: expected next token to be ,, but got IDENT instead",
+		"1:35: no prefix parse function for } found",
+		"1:40: cannot assign to 1",
+	}
+	if len(p.SyntaxErrors()) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%q, got=%v", expected, p.SyntaxErrors())
+	}
+	for i, err := range p.SyntaxErrors() {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong errors. want=%q, got=%v", expected, p.SyntaxErrors())
+			break
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	input := `let x = 5;
+let = 10;
+let y 3;`
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"2:5: expected next token to be IDENT, but got = instead",
+		"2:5: no prefix parse function for = found",
+		"3:7: expected next token to be =, but got INT instead",
+	}
+
+	errs := p.SyntaxErrors()
+	if len(errs) != len(expected) || len(p.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%v", len(expected), errs)
+	}
+
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error %d. want=%q, got=%q", i, expected[i], err.Error())
+		}
+
+		if err.Message != p.Errors()[i] {
+			t.Errorf("message %d differs from Errors. want=%q, got=%q", i, p.Errors()[i], err.Message)
+		}
+	}
+}
+
 // ============================================================================
 // HELPER FUNCTIONS
 // ============================================================================
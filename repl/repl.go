@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/lukeomalley/monkey_lang/compiler"
 	"github.com/lukeomalley/monkey_lang/lexer"
@@ -15,6 +16,9 @@ import (
 // PROMPT is the prompt shown in the repl
 const PROMPT = "👉 "
 
+// DISASM_COMMAND toggles printing the bytecode of every input before it runs
+const DISASM_COMMAND = ":disasm"
+
 // Start iniaializes the repl
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
@@ -22,14 +26,17 @@ func Start(in io.Reader, out io.Writer) {
 	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalsSize)
 	symbolTable := compiler.NewSymbolTable()
+	builtins := object.NewRegistry()
 
 	// Add builtin functions to REPL env
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
+	for i, b := range builtins.Builtins() {
+		symbolTable.DefineBuiltin(i, b.Name)
 	}
 
+	disasm := false
+
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 
 		if !scanned {
@@ -37,6 +44,16 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if strings.TrimSpace(line) == DISASM_COMMAND {
+			disasm = !disasm
+			if disasm {
+				io.WriteString(out, "disassembly on\n")
+			} else {
+				io.WriteString(out, "disassembly off\n")
+			}
+			continue
+		}
+
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
@@ -55,7 +72,12 @@ func Start(in io.Reader, out io.Writer) {
 		code := comp.Bytecode()
 		constants = code.Constants
 
-		machine := vm.NewWithGlobalsStore(code, globals)
+		if disasm {
+			io.WriteString(out, compiler.Disassemble(code))
+		}
+
+		machine := vm.NewWithState(code, globals, builtins)
+		machine.SetOutput(out, out)
 		err = machine.Run()
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
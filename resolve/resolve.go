@@ -0,0 +1,98 @@
+package resolve
+
+import (
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+// Resolver resolves names with symbol tables exactly as the compiler does, and
+// finds the binding each name refers to. A binding is whatever the caller
+// attaches to a name when defining it, such as its declaration or its type.
+type Resolver struct {
+	root      *compiler.SymbolTable
+	table     *compiler.SymbolTable
+	defs      map[*compiler.SymbolTable][]interface{} // bindings of each table, indexed by symbol index
+	functions map[*compiler.SymbolTable]interface{}   // binding of the function of each table
+}
+
+// New constructs a resolver at the top level of a program, where the builtins
+// of the registry are defined
+func New(builtins *object.Registry) *Resolver {
+	r := &Resolver{
+		root:      compiler.NewSymbolTable(),
+		defs:      make(map[*compiler.SymbolTable][]interface{}),
+		functions: make(map[*compiler.SymbolTable]interface{}),
+	}
+	r.table = r.root
+
+	for i, b := range builtins.Builtins() {
+		r.root.DefineBuiltin(i, b.Name)
+	}
+
+	return r
+}
+
+// Root returns the symbol table of the top level of the program
+func (r *Resolver) Root() *compiler.SymbolTable {
+	return r.root
+}
+
+// Table returns the symbol table of the innermost function being resolved
+func (r *Resolver) Table() *compiler.SymbolTable {
+	return r.table
+}
+
+// Enter starts the symbol table of a function. Unless name is empty, the
+// function refers to itself by name, which resolves to the binding given.
+func (r *Resolver) Enter(name string, binding interface{}) {
+	r.table = compiler.NewEnclosedSymbolTable(r.table)
+	if name != "" {
+		r.table.DefineFunctionName(name)
+		r.functions[r.table] = binding
+	}
+}
+
+// Leave returns to the symbol table enclosing the current function
+func (r *Resolver) Leave() {
+	r.table = r.table.Outer
+}
+
+// Define binds a name in the current table
+func (r *Resolver) Define(name string, binding interface{}) compiler.Symbol {
+	sym := r.table.Define(name)
+
+	defs := r.defs[r.table]
+	for len(defs) <= sym.Index {
+		defs = append(defs, nil)
+	}
+	defs[sym.Index] = binding
+	r.defs[r.table] = defs
+
+	return sym
+}
+
+// Resolve finds the symbol a name refers to in the current table
+func (r *Resolver) Resolve(name string) (compiler.Symbol, bool) {
+	return r.table.Resolve(name)
+}
+
+// Lookup returns the binding of a symbol resolved in the current table, nil
+// for builtins
+func (r *Resolver) Lookup(sym compiler.Symbol) interface{} {
+	return r.lookup(r.table, sym)
+}
+
+func (r *Resolver) lookup(table *compiler.SymbolTable, sym compiler.Symbol) interface{} {
+	switch sym.Scope {
+	case compiler.GlobalScope:
+		return r.defs[r.root][sym.Index]
+	case compiler.LocalScope:
+		return r.defs[table][sym.Index]
+	case compiler.FreeScope:
+		return r.lookup(table.Outer, table.FreeSymbols[sym.Index])
+	case compiler.FunctionScope:
+		return r.functions[table]
+	}
+
+	return nil
+}
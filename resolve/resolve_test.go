@@ -0,0 +1,61 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+func TestLookup(t *testing.T) {
+	r := New(object.NewRegistry())
+
+	r.Define("a", "global a")
+	r.Define("f", "let f")
+
+	// fn(b) { fn(c) { a + b + c + f } }, bound to f
+	r.Enter("f", "let f")
+	r.Define("b", "param b")
+	r.Enter("", nil)
+	r.Define("c", "param c")
+
+	tests := []struct {
+		name     string
+		scope    compiler.SymbolScope
+		expected interface{}
+	}{
+		{"a", compiler.GlobalScope, "global a"},
+		{"b", compiler.FreeScope, "param b"},
+		{"c", compiler.LocalScope, "param c"},
+		{"f", compiler.FreeScope, "let f"},
+		{"len", compiler.BuiltinScope, nil},
+	}
+
+	for _, tt := range tests {
+		sym, ok := r.Resolve(tt.name)
+		if !ok {
+			t.Fatalf("%s does not resolve", tt.name)
+		}
+
+		if sym.Scope != tt.scope {
+			t.Errorf("wrong scope for %s. want=%s, got=%s", tt.name, tt.scope, sym.Scope)
+		}
+
+		if got := r.Lookup(sym); got != tt.expected {
+			t.Errorf("wrong binding for %s. want=%v, got=%v", tt.name, tt.expected, got)
+		}
+	}
+
+	r.Leave()
+
+	// Within its own body, the name of a function refers to the function
+	sym, _ := r.Resolve("f")
+	if sym.Scope != compiler.FunctionScope || r.Lookup(sym) != "let f" {
+		t.Errorf("wrong binding for f in its body. got=%+v, %v", sym, r.Lookup(sym))
+	}
+
+	r.Leave()
+	if r.Table() != r.Root() {
+		t.Errorf("expected to be back at the top level")
+	}
+}
@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxContentLength bounds the size of a message, so that a bad header cannot
+// make the reader allocate an arbitrary amount of memory
+const MaxContentLength = 64 << 20
+
+// Read reads the content of a single message framed by a Content-Length header,
+// as used by the language server and debug adapter protocols
+func Read(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid header: %q", line)
+		}
+
+		name, value := line[:colon], line[colon+1:]
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+			if length > MaxContentLength {
+				return nil, fmt.Errorf("Content-Length %d exceeds the limit of %d bytes", length, MaxContentLength)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Write writes a message as JSON framed by a Content-Length header
+func Write(w io.Writer, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, map[string]int{"id": 1}); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	expected := "Content-Length: 8\r\n\r\n{\"id\":1}"
+	if buf.String() != expected {
+		t.Fatalf("wrong framing. want=%q, got=%q", expected, buf.String())
+	}
+
+	data, err := Read(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	if string(data) != `{"id":1}` {
+		t.Errorf("wrong content. got=%q", data)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Type: json\r\n\r\n{}", "missing Content-Length header"},
+		{"Content-Length 2\r\n\r\n{}", `invalid header: "Content-Length 2"`},
+		{"Content-Length: two\r\n\r\n{}", `invalid Content-Length: " two"`},
+		{"Content-Length: -1\r\n\r\n{}", `invalid Content-Length: " -1"`},
+		{"Content-Length: 99999999999\r\n\r\n{}", "Content-Length 99999999999 exceeds the limit of 67108864 bytes"},
+		{"Content-Length: 4\r\n\r\n{}", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"strconv"
+
+	"github.com/lukeomalley/monkey_lang/object"
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// Hooks are called by the vm while it runs, letting a host such as a debugger
+// observe execution. A hook that blocks pauses the vm until it returns. Any
+// hook may be nil.
+type Hooks struct {
+	// BeforeInstruction is called before each instruction is executed. Returning
+	// an error stops the vm, and Run returns the error as a *RuntimeError.
+	BeforeInstruction func(vm *VM) error
+
+	// OnCall is called once a closure has been called and its frame entered
+	OnCall func(vm *VM)
+
+	// OnReturn is called once a closure has returned value to its caller
+	OnReturn func(vm *VM, value object.Object)
+}
+
+// FrameInfo describes a call frame of a paused vm
+type FrameInfo struct {
+	Name string         // name of the function, "main" for the main program
+	Pos  token.Position // position of the instruction being executed
+	IP   int            // offset of the instruction being executed
+}
+
+// Variable is a named value visible to a frame
+type Variable struct {
+	Name  string
+	Value object.Object // nil for locals that have not been assigned yet
+}
+
+// SetHooks installs the hooks called while the vm runs, nil removes them
+func (vm *VM) SetHooks(hooks *Hooks) {
+	vm.hooks = hooks
+}
+
+// CallDepth returns the number of active frames, including the main frame
+func (vm *VM) CallDepth() int {
+	return vm.framesIndex
+}
+
+// Position returns the source position of the instruction being executed
+func (vm *VM) Position() token.Position {
+	frame := vm.currentFrame()
+	return frame.cl.Fn.SourceMap.Lookup(frame.ip)
+}
+
+// Frames returns the active call frames, starting with the innermost
+func (vm *VM) Frames() []FrameInfo {
+	frames := make([]FrameInfo, vm.framesIndex)
+	for i := range frames {
+		frame := vm.frames[vm.framesIndex-1-i]
+
+		name := frame.cl.Fn.Name
+		switch {
+		case frame == vm.frames[0]:
+			name = "main"
+		case name == "":
+			name = "<anonymous>"
+		}
+
+		frames[i] = FrameInfo{Name: name, Pos: frame.cl.Fn.SourceMap.Lookup(frame.ip), IP: frame.ip}
+	}
+
+	return frames
+}
+
+// Locals returns the parameters and local bindings of a frame, where frame 0
+// is the innermost as returned by Frames. The main program has no locals.
+func (vm *VM) Locals(frameIndex int) []Variable {
+	frame, ok := vm.frameAt(frameIndex)
+	if !ok {
+		return nil
+	}
+
+	vars := make([]Variable, len(frame.cl.Fn.LocalNames))
+	for i, name := range frame.cl.Fn.LocalNames {
+		vars[i] = Variable{Name: name, Value: vm.stack[frame.basePointer+i]}
+	}
+
+	return vars
+}
+
+// FreeVariables returns the variables captured by the closure of a frame
+func (vm *VM) FreeVariables(frameIndex int) []Variable {
+	frame, ok := vm.frameAt(frameIndex)
+	if !ok {
+		return nil
+	}
+
+	vars := make([]Variable, 0, len(frame.cl.Free))
+	for i, value := range frame.cl.Free {
+		name := "#" + strconv.Itoa(i)
+		if i < len(frame.cl.Fn.FreeNames) {
+			name = frame.cl.Fn.FreeNames[i]
+		}
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+
+	return vars
+}
+
+// Globals returns the globals that have been assigned, using the names from
+// the bytecode the vm was constructed with
+func (vm *VM) Globals() []Variable {
+	vars := []Variable{}
+	for i, name := range vm.globalNames {
+		if i < len(vm.globals) && vm.globals[i] != nil {
+			vars = append(vars, Variable{Name: name, Value: vm.globals[i]})
+		}
+	}
+
+	return vars
+}
+
+func (vm *VM) frameAt(frameIndex int) (*Frame, bool) {
+	if frameIndex < 0 || frameIndex >= vm.framesIndex {
+		return nil, false
+	}
+
+	return vm.frames[vm.framesIndex-1-frameIndex], true
+}
@@ -0,0 +1,37 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/lukeomalley/monkey_lang/token"
+)
+
+// RuntimeError is returned when executing bytecode fails. Pos is the source
+// position of the failing instruction when the bytecode has a source map.
+type RuntimeError struct {
+	Message string
+	Pos     token.Position
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+	}
+
+	return e.Message
+}
+
+// runtimeError attaches the position of the current instruction to err
+func (vm *VM) runtimeError(err error) *RuntimeError {
+	if rerr, ok := err.(*RuntimeError); ok {
+		return rerr
+	}
+
+	rerr := &RuntimeError{Message: err.Error()}
+	if vm.framesIndex > 0 && vm.framesIndex <= len(vm.frames) {
+		frame := vm.currentFrame()
+		rerr.Pos = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+	}
+
+	return rerr
+}
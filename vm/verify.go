@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/lukeomalley/monkey_lang/code"
+	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/object"
+)
+
+// Verify checks the main program and every compiled function in the constant
+// pool with code.Verify, so that bytecode that was not produced by the compiler
+// can be rejected before it is run. A closure over a function must capture as
+// many free variables as the function has FreeNames.
+func Verify(bytecode *compiler.Bytecode, builtins *object.Registry) error {
+	functions := make(map[int]int)
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			functions[i] = len(fn.FreeNames)
+		}
+	}
+
+	limits := code.Limits{
+		NumConstants: len(bytecode.Constants),
+		NumBuiltins:  len(builtins.Builtins()),
+		Functions:    functions,
+	}
+
+	if err := code.Verify(bytecode.Instructions, limits); err != nil {
+		return fmt.Errorf("invalid bytecode in main program: %w", err)
+	}
+
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("invalid bytecode in function at constant %d: %d parameters exceed %d locals", i, fn.NumParameters, fn.NumLocals)
+		}
+
+		fnLimits := limits
+		fnLimits.NumLocals = fn.NumLocals
+		fnLimits.NumFree = len(fn.FreeNames)
+		fnLimits.Returns = true
+
+		if err := code.Verify(fn.Instructions, fnLimits); err != nil {
+			return fmt.Errorf("invalid bytecode in function at constant %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
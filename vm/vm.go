@@ -2,6 +2,8 @@ package vm
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/lukeomalley/monkey_lang/code"
 	"github.com/lukeomalley/monkey_lang/compiler"
@@ -9,13 +11,13 @@ import (
 )
 
 // True is the global true value referenced throughout the vm
-var True = &object.Boolean{Value: true}
+var True = object.TRUE
 
 // False is the global false value referenced throughout the vm
-var False = &object.Boolean{Value: false}
+var False = object.FALSE
 
 // Null is the global null value referenced through the vm
-var Null = &object.Null{}
+var Null = object.NULL
 
 // StackSize sets the maximum size of the stack
 const StackSize = 2048
@@ -28,32 +30,61 @@ const MaxFrames = 1024
 
 // VM takes bytecode instrutions and evaluates them
 type VM struct {
+	bytecode    *compiler.Bytecode
+	verified    bool // set once Run has verified the bytecode
 	constants   []object.Object
 	globals     []object.Object
 	stack       []object.Object
 	sp          int // Always points to the next value, top of stack is (sp - 1)
 	frames      []*Frame
 	framesIndex int
+	builtins    *object.Registry
+	stdout      io.Writer
+	stderr      io.Writer
+	hooks       *Hooks
+	halt        error // runtime error raised in a nested run, which stops every enclosing run
+	running     int   // number of nested runs in progress
+	globalNames []string
 }
 
 // New constructs a VM
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
 
 	return &VM{
+		bytecode:    bytecode,
 		constants:   bytecode.Constants,
 		globals:     make([]object.Object, GlobalsSize),
 		stack:       make([]object.Object, StackSize),
 		sp:          0,
 		frames:      frames,
 		framesIndex: 1,
+		builtins:    object.NewRegistry(),
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		globalNames: bytecode.GlobalNames,
 	}
 }
 
+// NewWithBuiltins constructs a VM that resolves builtin functions from the registry used to compile the bytecode
+func NewWithBuiltins(bytecode *compiler.Bytecode, builtins *object.Registry) *VM {
+	vm := New(bytecode)
+	vm.builtins = builtins
+	return vm
+}
+
+// NewWithState constructs a VM with the globals from a previous instance of a VM and the given builtin registry
+func NewWithState(bytecode *compiler.Bytecode, globals []object.Object, builtins *object.Registry) *VM {
+	vm := New(bytecode)
+	vm.globals = globals
+	vm.builtins = builtins
+	return vm
+}
+
 // NewWithGlobalsStore constructs a new VM with the globals from a previous instance of a VM
 func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
 	vm := New(bytecode)
@@ -61,19 +92,146 @@ func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *
 	return vm
 }
 
-// Run executes the bytecode operations
+// SetOutput redirects the output of the printing builtins. A nil writer
+// discards the output.
+func (vm *VM) SetOutput(stdout, stderr io.Writer) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	vm.stdout, vm.stderr = stdout, stderr
+}
+
+// Stdout returns the writer used by puts and print
+func (vm *VM) Stdout() io.Writer {
+	return vm.stdout
+}
+
+// Stderr returns the writer used by eprint
+func (vm *VM) Stderr() io.Writer {
+	return vm.stderr
+}
+
+// Run executes the bytecode operations. The bytecode is checked with Verify
+// before it first runs. Failures are reported as a *RuntimeError, including
+// bytecode that fails verification and any panic raised while executing, which
+// is reported as an internal error.
 func (vm *VM) Run() error {
+	if !vm.verified {
+		if err := Verify(vm.bytecode, vm.builtins); err != nil {
+			return &RuntimeError{Message: err.Error()}
+		}
+		vm.verified = true
+	}
+
+	vm.halt = nil
+	return vm.run(0)
+}
+
+// Call applies a closure or builtin to the arguments and runs the vm until it
+// returns. It is used by builtins to call back into Monkey code, and by hosts
+// to invoke functions returned from a script once Run has completed.
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		if result := fn.Fn(vm, args...); result != nil {
+			return result
+		}
+
+		return Null
+
+	case *object.Closure:
+		// A host call starts afresh, while a call from a builtin shares the halt of its run
+		if vm.running == 0 {
+			vm.halt = nil
+		}
+
+		basePointer := vm.sp
+		depth := vm.framesIndex
+
+		err := vm.push(fn)
+		for _, arg := range args {
+			if err != nil {
+				break
+			}
+			err = vm.push(arg)
+		}
+
+		if err == nil {
+			err = vm.callClosure(fn, len(args))
+		}
+
+		if err == nil {
+			err = vm.run(depth)
+		}
+
+		if err != nil {
+			vm.framesIndex = depth
+			vm.sp = basePointer
+
+			// Stop the enclosing run too, so the error is not swallowed by the builtin
+			rerr := vm.runtimeError(err)
+			vm.halt = rerr
+			return &object.Error{Message: rerr.Message, Pos: rerr.Pos}
+		}
+
+		result := vm.pop()
+		vm.sp = basePointer
+		return result
+
+	case *object.StructType:
+		instance, err := fn.New(args)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		return instance
+
+	default:
+		return &object.Error{Message: fmt.Sprintf("not a function: %s", fn.Type())}
+	}
+}
+
+// run executes instructions until the frame at index depth returns, or the main frame is exhausted
+func (vm *VM) run(depth int) (err error) {
+	vm.running++
+	defer func() {
+		vm.running--
+
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+
+		if err != nil {
+			err = vm.runtimeError(err)
+		}
+	}()
+
+	return vm.execute(depth)
+}
+
+func (vm *VM) execute(depth int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
 		vm.currentFrame().ip++
 
 		ip = vm.currentFrame().ip
 		ins = vm.currentFrame().Instructions()
 		op = code.Opcode(ins[ip])
 
+		if vm.hooks != nil && vm.hooks.BeforeInstruction != nil {
+			if err := vm.hooks.BeforeInstruction(vm); err != nil {
+				vm.halt = vm.runtimeError(err)
+				return vm.halt
+			}
+		}
+
 		switch op {
 		case code.OpConstant:
 			constIndex := code.ReadUint16(ins[ip+1:])
@@ -87,7 +245,7 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpEqual, code.OpGreaterThan, code.OpNotEqual:
+		case code.OpEqual, code.OpGreaterThan, code.OpLessThan, code.OpNotEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -186,6 +344,55 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			slice, err := object.Slice(left, start, end)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(slice)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetField:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			s, name, err := vm.fieldOf(vm.pop(), int(nameIndex))
+			if err != nil {
+				return err
+			}
+
+			value, err := s.Get(name)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetField:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.pop()
+			s, name, err := vm.fieldOf(vm.pop(), int(nameIndex))
+			if err != nil {
+				return err
+			}
+
+			err = s.Set(name, value)
+			if err != nil {
+				return err
+			}
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
@@ -205,6 +412,10 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+			if vm.hooks != nil && vm.hooks.OnReturn != nil {
+				vm.hooks.OnReturn(vm, returnValue)
+			}
+
 		case code.OpReturn:
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
@@ -214,6 +425,10 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+			if vm.hooks != nil && vm.hooks.OnReturn != nil {
+				vm.hooks.OnReturn(vm, Null)
+			}
+
 		case code.OpSetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
@@ -234,12 +449,15 @@ func (vm *VM) Run() error {
 			}
 
 		case code.OpGetBuiltin:
-			builtinIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip += 1
+			builtinIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 
-			definition := object.Builtins[builtinIndex]
+			builtin, ok := vm.builtins.Get(int(builtinIndex))
+			if !ok {
+				return fmt.Errorf("undefined builtin: %d", builtinIndex)
+			}
 
-			err := vm.push(definition.Builtin)
+			err := vm.push(builtin)
 			if err != nil {
 				return err
 			}
@@ -306,8 +524,10 @@ func (vm *VM) executeCall(numArgs int) error {
 		return vm.callClosure(callee, numArgs)
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
+	case *object.StructType:
+		return vm.callStruct(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-closure and non-builtin")
+		return fmt.Errorf("not a function: %s", callee.Type())
 
 	}
 }
@@ -317,19 +537,49 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
 	}
 
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow: exceeded %d nested calls", MaxFrames)
+	}
+
+	if vm.sp-numArgs+cl.Fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
 	frame := NewFrame(cl, vm.sp-numArgs)
 	vm.pushFrame(frame)
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
 
+	// Clear locals left behind by earlier calls, so they are not mistaken for bindings
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	if vm.hooks != nil && vm.hooks.OnCall != nil {
+		vm.hooks.OnCall(vm)
+	}
+
 	return nil
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
-	result := builtin.Fn(args...)
+	result := builtin.Fn(vm, args...)
 	vm.sp = vm.sp - numArgs - 1
 
+	// A callback into Monkey code failed or was stopped by a hook
+	if vm.halt != nil {
+		return vm.halt
+	}
+
+	// A failing builtin stops the run, as it does in the evaluator
+	if err, ok := result.(*object.Error); ok {
+		if err.Pos.IsValid() {
+			return &RuntimeError{Message: err.Message, Pos: err.Pos}
+		}
+		return vm.runtimeError(fmt.Errorf("%s", err.Message))
+	}
+
 	if result != nil {
 		vm.push(result)
 	} else {
@@ -339,9 +589,36 @@ func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// callStruct constructs an instance of a struct type from the arguments
+func (vm *VM) callStruct(st *object.StructType, numArgs int) error {
+	instance, err := st.New(vm.stack[vm.sp-numArgs : vm.sp])
+	if err != nil {
+		return err
+	}
+
+	vm.sp = vm.sp - numArgs - 1
+	return vm.push(instance)
+}
+
+// fieldOf returns the struct whose field is read or written by OpGetField or
+// OpSetField, along with the name of the field held in the constant pool
+func (vm *VM) fieldOf(obj object.Object, nameIndex int) (*object.Struct, string, error) {
+	s, ok := obj.(*object.Struct)
+	if !ok {
+		return nil, "", fmt.Errorf("field access not supported: %s", obj.Type())
+	}
+
+	name, ok := vm.constants[nameIndex].(*object.String)
+	if !ok {
+		return nil, "", fmt.Errorf("field name is not a string: %s", vm.constants[nameIndex].Type())
+	}
+
+	return s, name.Value, nil
+}
+
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+	case left.Type() == object.ARRAY_OBJ:
 		return vm.executeArrayIndex(left, index)
 
 	case left.Type() == object.HASH_OBJ:
@@ -353,8 +630,17 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 }
 
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
-	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
+	arrayObject, ok := array.(*object.Array)
+	if !ok {
+		return fmt.Errorf("index operator not supported: %s", array.Type())
+	}
+
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("array index must be INTEGER. got=%s", index.Type())
+	}
+
+	i := integer.Value
 	max := int64(len(arrayObject.Elements) - 1)
 
 	if i < 0 || i > max {
@@ -365,39 +651,37 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 }
 
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
-	hashObject := hash.(*object.Hash)
-
-	key, ok := index.(object.Hashable)
+	hashObject, ok := hash.(*object.Hash)
 	if !ok {
+		return fmt.Errorf("index operator not supported: %s", hash.Type())
+	}
+
+	if !object.IsHashable(index) {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return vm.push(Null)
 	}
 
-	return vm.push(pair.Value)
+	return vm.push(value)
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
 	for i := startIndex; i < endIndex; i += 2 {
 		key := vm.stack[i]
 		value := vm.stack[i+1]
 
-		pair := object.HashPair{Key: key, Value: value}
-
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		err := hash.Set(key, value)
+		if err != nil {
+			return nil, err
 		}
-
-		hashedPairs[hashKey.HashKey()] = pair
 	}
 
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return hash, nil
 }
 
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
@@ -413,12 +697,14 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 func (vm *VM) executeMinusOperator(op code.Opcode) error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
-
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
 }
 
 func (vm *VM) executeBangOperator(op code.Opcode) error {
@@ -447,6 +733,13 @@ func (vm *VM) executeBianryOperation(op code.Opcode) error {
 		return vm.executeBinaryIntegerOperation(op, left, right)
 	}
 
+	// Mixing an integer with a float promotes the integer
+	leftFloat, leftOk := object.FloatValue(left)
+	rightFloat, rightOk := object.FloatValue(right)
+	if leftOk && rightOk {
+		return vm.executeBinaryFloatOperation(op, leftFloat, rightFloat)
+	}
+
 	if leftType == object.STRING_OBJ && rightType == object.STRING_OBJ {
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
@@ -481,6 +774,9 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
@@ -490,40 +786,64 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	return vm.push(&object.Integer{Value: result})
 }
 
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, leftValue, rightValue float64) error {
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue / rightValue
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeComparison(op code.Opcode) error {
 	// Pop two values off of the stack
 	right := vm.pop()
 	left := vm.pop()
 
 	// Execute the comparison
-	if left.Type() == object.INTEGER_OBJ || right.Type() == object.INTEGER_OBJ {
-		return vm.executeIntegerComparison(op, left, right)
-	}
-
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(left == right))
+		return vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(left != right))
+		return vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
+	case code.OpGreaterThan:
+		return vm.executeOrderedComparison(">", left, right)
+	case code.OpLessThan:
+		return vm.executeOrderedComparison("<", left, right)
 	default:
 		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
 	}
 }
 
-func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+// executeOrderedComparison handles OpGreaterThan and OpLessThan, operator is
+// the source operator reported in errors
+func (vm *VM) executeOrderedComparison(operator string, left, right object.Object) error {
+	result, ok := object.Compare(left, right)
+	if !ok {
+		if left.Type() != right.Type() {
+			return fmt.Errorf("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		}
 
-	switch op {
-	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
-	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
-	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
-	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	if operator == "<" {
+		return vm.push(nativeBoolToBooleanObject(result < 0))
 	}
+	return vm.push(nativeBoolToBooleanObject(result > 0))
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
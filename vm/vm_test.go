@@ -2,10 +2,13 @@ package vm
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/lukeomalley/monkey_lang/ast"
+	"github.com/lukeomalley/monkey_lang/code"
 	"github.com/lukeomalley/monkey_lang/compiler"
+	"github.com/lukeomalley/monkey_lang/evaluator"
 	"github.com/lukeomalley/monkey_lang/lexer"
 	"github.com/lukeomalley/monkey_lang/object"
 	"github.com/lukeomalley/monkey_lang/parser"
@@ -34,6 +37,25 @@ func TestIntegerArithmetic(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.25", 2.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"7 / 2", 3},
+		{"1.5 > 1", true},
+		{"1 < 1.5", true},
+		{"2 == 2.0", true},
+		{"2.5 != 2.5", false},
+		{"[1.0, 2] == [1, 2.0]", true},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"true", true},
@@ -46,11 +68,168 @@ func TestBooleanExpressions(t *testing.T) {
 		{"true != true", false},
 		{"!(if (false) { 5; })", true},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" != "a"`, false},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"" < "a"`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`1 == "1"`, false},
+		{`"1" != 1`, true},
+		{"1 == true", false},
+		{"[] == {}", false},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestComparisonErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{input: `1 > "a"`, expected: "1:3: type mismatch: INTEGER > STRING"},
+		{input: `1 < "a"`, expected: "1:3: type mismatch: INTEGER < STRING"},
+		{input: "[1] < [2]", expected: "1:5: unknown operator: ARRAY < ARRAY"},
+		{input: "[1] > [0]", expected: "1:5: unknown operator: ARRAY > ARRAY"},
+		{input: "true > false", expected: "1:6: unknown operator: BOOLEAN > BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q, but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error. want=%q, got=%q", tt.expected, err)
+		}
+
+		// The evaluator reports the same error
+		evaluated := evaluator.Eval(parse(tt.input), object.NewEnvironment())
+		if evaluated.Inspect() != "Error: "+tt.expected.(string) {
+			t.Errorf("wrong evaluator error. want=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{input: "1 / 0", expected: "1:3: division by zero"},
+		{input: "1.5 / 0", expected: "1:5: division by zero"},
+		{
+			input:    "let div = fn(a, b) {\n  a / b\n};\ndiv(10, 0)",
+			expected: "2:5: division by zero",
+		},
+		{input: `[1, 2]["a"]`, expected: "1:7: array index must be INTEGER. got=STRING"},
+		{input: `1[0]`, expected: "1:2: index operator not supported: INTEGER"},
+		{input: `-"a"`, expected: "1:1: unsupported type for negation: STRING"},
+		{input: `1 + "a"`, expected: "1:3: unsupported types for binary operation: INTEGER STRING"},
+		{input: `1()`, expected: "1:2: not a function: INTEGER"},
+		{input: `1[1:]`, expected: "1:2: slice operator not supported: INTEGER"},
+		{input: `[1]["a":]`, expected: "1:4: slice index must be INTEGER. got=STRING"},
+		{input: `struct P { x }; P(1).y`, expected: "1:21: unknown field y in P"},
+		{input: `struct P { x }; P(1, 2)`, expected: "1:18: wrong number of arguments: want=1, got=2"},
+		{input: `let a = 1; a.x = 2`, expected: "1:12: field access not supported: INTEGER"},
+		{input: `[1].x`, expected: "1:4: field access not supported: ARRAY"},
+		{
+			input:    "let f = fn() { f() }; f()",
+			expected: "1:17: stack overflow: exceeded 1024 nested calls",
+		},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q, but resulted in none.", tt.input)
+		}
+
+		if _, ok := err.(*RuntimeError); !ok {
+			t.Errorf("error is not *RuntimeError. got=%T", err)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []vmTestCase{
+		{"struct Point { x, y }; let p = Point(1, 2); p.x + p.y", 3},
+		{"struct Point { x, y }; let p = Point(1, 2); p.x = 10; p.x * p.y", 20},
+		{"struct Point { x, y }; let p = Point(1, 2); let q = p; q.x = 5; p.x", 5},
+		{"struct Point { x, y }; str(Point(1, [2]))", "Point{x: 1, y: [2]}"},
+		{`struct Point { x, y }; inspect(Point("a", 2))`, `Point{x: "a", y: 2}`},
+		{"struct Point { x, y }; type(Point(1, 2))", "Point"},
+		{"struct Point { x, y }; Point(1, 2) == Point(1.0, 2)", true},
+		{"struct Point { x, y }; Point(1, 2) != Point(2, 1)", true},
+		{"struct Point { x, y }; struct Line { from, to }; let l = Line(Point(0, 0), Point(1, 2)); l.to.y = 5; l.to.y", 5},
+		{"struct Point { x, y }; let move = fn(p, dx) { if (dx > 0) { p.x = p.x + dx } p }; move(Point(1, 2), 3).x", 4},
+		{"let f = fn(n) { struct Box { value }; Box(n) }; f(1).value + f(2).value", 3},
+		{"struct Point { x, y }; map([1, 2], fn(n) { Point(n, n * 2) })[1].y", 4},
+		{"if (true) { let x = 1; }", Null},
+		{"struct Node { v, next }; let a = Node(1, 0); let b = Node(1, 0); a.next = a; b.next = b; a == b", true},
+		{"struct Node { v, next }; let a = Node(1, 0); let b = Node(1, 0); a.next = a; b.next = Node(2, b); a == b", false},
+		{"struct Node { v, next }; let a = Node(1, 0); a.next = a; str(a)", "Node{v: 1, next: <cycle>}"},
+		{`struct Node { v, next }; let a = Node("a", 0); a.next = [a, {"b": a}]; inspect(a)`, `Node{v: "a", next: [<cycle>, {"b": <cycle>}]}`},
 	}
 
 	runVMTests(t, tests)
 }
 
+func TestRecoverFromPanics(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Register("boom", 0, func(ctx object.CallContext, args ...object.Object) object.Object {
+		panic("boom")
+	})
+
+	comp := compiler.NewWithBuiltins(builtins)
+	if err := comp.Compile(parse(`boom()`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := NewWithBuiltins(comp.Bytecode(), builtins)
+	err := vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error, but resulted in none.")
+	}
+
+	if err.Error() != "1:5: internal error: boom" {
+		t.Errorf("wrong VM error. got=%q", err)
+	}
+}
+
+func TestRunVerifiesBytecode(t *testing.T) {
+	// A constant index past the end of the constant pool would panic inside the vm
+	bytecode := &compiler.Bytecode{Instructions: code.Make(code.OpConstant, 5)}
+
+	vm := New(bytecode)
+	err := vm.Run()
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+
+	expected := "invalid bytecode in main program: 0000: constant index 5 out of range, 0 constants"
+	if err.Error() != expected {
+		t.Errorf("wrong VM error. want=%q, got=%q", expected, err)
+	}
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []vmTestCase{
 		{input: "if (true) { 10 }", expected: 10},
@@ -122,6 +301,27 @@ func TestHashLiterals(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestHashInspectOrder(t *testing.T) {
+	input := `let h = {"zebra": 1, "apple": 2, [1, 2]: 3, true: 4}; h`
+	expected := "{zebra: 1, apple: 2, [1, 2]: 3, true: 4}"
+
+	for i := 0; i < 10; i++ {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != expected {
+			t.Fatalf("wrong hash output. want=%q, got=%q", expected, got)
+		}
+	}
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{input: "[1, 2, 3][1]", expected: 2},
@@ -133,6 +333,30 @@ func TestIndexExpressions(t *testing.T) {
 		{input: "{1: 1, 2: 2}[2]", expected: 2},
 		{input: "{1: 1}[0]", expected: Null},
 		{input: "{}[0]", expected: Null},
+		{input: "{[1, 2]: 3}[[1, 2]]", expected: 3},
+		{input: "{[1, 2]: 3}[[2, 1]]", expected: Null},
+		{input: `{[1, ["a"]]: 4}[[1, ["a"]]]`, expected: 4},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"let a = [1, 2, 3]; let n = 2; a[n - 1:n + 1]", []int{2, 3}},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[:3]`, "mon"},
+		{`"monkey"[3:]`, "key"},
+		{`"monkey"[-3:]`, "key"},
+		{`"monkey"[4:2]`, ""},
 	}
 
 	runVMTests(t, tests)
@@ -361,15 +585,15 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
 			input:    `fn() { 1; }(1);`,
-			expected: `wrong number of arguments: want=0, got=1`,
+			expected: `1:12: wrong number of arguments: want=0, got=1`,
 		},
 		{
 			input:    `fn(a) { a; }();`,
-			expected: `wrong number of arguments: want=1, got=0`,
+			expected: `1:13: wrong number of arguments: want=1, got=0`,
 		},
 		{
 			input:    `fn(a, b) { a + b; }(1);`,
-			expected: `wrong number of arguments: want=2, got=1`,
+			expected: `1:20: wrong number of arguments: want=2, got=1`,
 		},
 	}
 
@@ -419,6 +643,644 @@ func TestBuiltinFunctions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`split(1, ",")`, &object.Error{Message: "argument to `split` must be STRING. got=INTEGER"}},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([], "-")`, ""},
+		{`join(["a", 1], "-")`, &object.Error{Message: "elements passed to `join` must be STRING. got=INTEGER"}},
+		{`trim("  monkey  ")`, "monkey"},
+		{`trim("xxmonkeyx", "x")`, "monkey"},
+		{`upper("Monkey")`, "MONKEY"},
+		{`lower("Monkey")`, "monkey"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "ape")`, false},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`starts_with("monkey", "mon")`, true},
+		{`starts_with("monkey", "key")`, false},
+		{`ends_with("monkey", "key")`, true},
+		{`index_of("monkey", "key")`, 3},
+		{`index_of("monkey", "ape")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, &object.Error{Message: "negative count passed to `repeat`: -1"}},
+		{`chars("héllo")`, []string{"h", "é", "l", "l", "o"}},
+		{`format("%s is %d", "x", 5)`, "x is 5"},
+		{`format("%t %v %v", true, [1, "a"], {"a": 1})`, "true [1, a] {a: 1}"},
+		{`format("%05d|%-3s|", 42, "a")`, "00042|a  |"},
+		{`format("%f", 1.5)`, "1.500000"},
+		{`format("%.2f", 1.5)`, "1.50"},
+		{`format("%v and %v", 2.25, 3)`, "2.25 and 3"},
+		{`format()`, &object.Error{Message: "wrong number of arguments. got=0, want at least 1"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map([], fn(x) { x * 2 })`, []int{}},
+		{`map(1, fn(x) { x })`, &object.Error{Message: "argument to `map` must be an ARRAY. got=INTEGER"}},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, []int{3, 4}},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 10)`, 20},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc * x })`, 24},
+		{`reduce([], fn(acc, x) { acc + x })`, &object.Error{Message: "`reduce` of empty ARRAY with no initial value"}},
+		{`sort([3, 1, 2])`, []int{1, 2, 3}},
+		{`sort(["b", "c", "a"])`, []string{"a", "b", "c"}},
+		{`sort([1, "a"])`, &object.Error{Message: "cannot sort INTEGER and STRING"}},
+		{`sort_by(["ccc", "a", "bb"], fn(s) { len(s) })`, []string{"a", "bb", "ccc"}},
+		{`sort_by([[2, "x"], [1, "y"], [2, "z"]], fn(p) { p[0] })[1][1]`, "x"},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`reverse("abc")`, "cba"},
+		{`range(4)`, []int{0, 1, 2, 3}},
+		{`range(2, 5)`, []int{2, 3, 4}},
+		{`range(5, 0, -2)`, []int{5, 3, 1}},
+		{`range(0, 5, 0)`, &object.Error{Message: "step passed to `range` must not be zero"}},
+		{`range(9223372036854775805, 9223372036854775807, 2)`, []int{9223372036854775805}},
+		{`range(-9223372036854775807, -9223372036854775807 - 1, -5)`, []int{-9223372036854775807}},
+		{`len(range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807))`, 3},
+		{`zip([1, 2, 3], ["a", "b"])[1][1]`, "b"},
+		{`len(zip([1, 2, 3], ["a", "b"]))`, 2},
+		{`enumerate(["a", "b"])[1][0]`, 1},
+		{`contains([1, [2]], [2])`, true},
+		{`contains([1, 2], 3)`, false},
+		{`contains({"a": 1}, "a")`, true},
+		{`contains("monkey", "key")`, true},
+		{`keys({"b": 1, "a": 2})`, []string{"b", "a"}},
+		{`values({"b": 1, "a": 2})`, []int{1, 2}},
+		{`has_key({"a": 1}, "a")`, true},
+		{`has_key({"a": 1}, "b")`, false},
+		{`has_key({"a": 1}, [fn() {}])`, &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [len(keys(h)), len(keys(d))]`, []int{2, 1}},
+		{`keys(merge({"a": 1, "b": 2}, {"c": 3, "a": 4}))`, []string{"a", "b", "c"}},
+		{`merge({"a": 1}, {"a": 4})["a"]`, 4},
+		{`flatten([1, [2, 3], [], [4]])`, []int{1, 2, 3, 4}},
+		{`unique([1, 2, 1, 3, 2])`, []int{1, 2, 3}},
+		{`len(unique([[1], [1], fn() {}]))`, 2},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{"math.abs(-3)", 3},
+		{"math.abs(-1.5)", 1.5},
+		{`math.abs("a")`, &object.Error{Message: "argument to `math.abs` must be INTEGER or FLOAT. got=STRING"}},
+		{"math.abs(-9223372036854775807 - 1)", &object.Error{Message: "integer overflow in `math.abs`: -9223372036854775808"}},
+		{"math.min(3, 1, 2)", 1},
+		{"math.max(3, 4.5, 2)", 4.5},
+		{"math.max([1, 5, 2])", 5},
+		{"math.max([])", &object.Error{Message: "empty ARRAY passed to `math.max`"}},
+		{"math.pow(2, 10)", 1024},
+		{"math.pow(4, 0.5)", 2.0},
+		{"math.pow(2, -1)", 0.5},
+		{"math.pow(2, 62)", 4611686018427387904},
+		{"math.pow(-2, 63)", -9223372036854775807 - 1},
+		{"math.pow(2, 63)", &object.Error{Message: "integer overflow in `math.pow`: 2 ** 63"}},
+		{"math.pow(2, 64)", &object.Error{Message: "integer overflow in `math.pow`: 2 ** 64"}},
+		{"math.pow(-1, 9223372036854775807)", -1},
+		{"math.sqrt(16)", 4.0},
+		{"math.sqrt(-1)", &object.Error{Message: "argument to `math.sqrt` must not be negative. got=-1"}},
+		{"math.floor(2.7)", 2},
+		{"math.floor(-2.5)", -3},
+		{"math.ceil(2.1)", 3},
+		{"math.round(2.5)", 3},
+		{"math.floor(7)", 7},
+		{"let r = math.random(); if (r < 0) { false } else { r < 1 }", true},
+		{"math.random_int(0)", &object.Error{Message: "empty range passed to `math.random_int`: 0..0"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestSeededRandom(t *testing.T) {
+	input := "[math.random_int(1000000), math.random_int(10, 20), math.random()]"
+
+	run := func() string {
+		builtins := object.NewRegistry()
+		builtins.Seed(42)
+
+		comp := compiler.NewWithBuiltins(builtins)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := NewWithBuiltins(comp.Bytecode(), builtins)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		return vm.LastPoppedStackElem().Inspect()
+	}
+
+	first := run()
+	if second := run(); first != second {
+		t.Errorf("seeded runs differ. first=%s, second=%s", first, second)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`json_parse("42")`, 42},
+		{`json_parse("1.5")`, 1.5},
+		{`json_parse("[1, [true, null], 3]")[1][0]`, true},
+		{`json_parse(" [1, 2, 3] ")`, []int{1, 2, 3}},
+		{`json_parse("[1, 2")`, &object.Error{Message: "invalid JSON: unexpected end of JSON input"}},
+		{`json_stringify({"b": [1, 2.5, json_parse("null")], "a": true})`, `{"b":[1,2.5,null],"a":true}`},
+		{`json_stringify([1, [2]], 1)`, "[\n 1,\n [\n  2\n ]\n]"},
+		{`json_stringify([1.0, -2.0, 1000000000000000000000.0])`, `[1.0,-2.0,1e+21]`},
+		{`type(json_parse(json_stringify(1.0)))`, "FLOAT"},
+		{`json_parse(json_stringify({"k": [1, "two"]}))["k"][1]`, "two"},
+		{`json_stringify(fn() {})`, &object.Error{Message: "cannot encode as JSON: unsupported type CLOSURE"}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(first([]))`, "NULL"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`let x = 1; type(fn() { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`str(42) + "!"`, "42!"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`int("42") + 1`, "43"},
+		{`int(" -7 ")`, "-7"},
+		{`int(2.9)`, "2"},
+		{`int(true)`, "1"},
+		{`bool(0)`, "true"},
+		{`bool(first([]))`, "false"},
+		{`inspect("a")`, `"a"`},
+		{`inspect(["a", 1, {"k": "v"}])`, `["a", 1, {"k": "v"}]`},
+		{`arity(fn(a, b) { a })`, "2"},
+		{`arity(len)`, "1"},
+		{`arity(range)`, "1"},
+		{`let add = fn(a, b) { a + b }; name(add)`, "add"},
+		{`name(fn() {})`, "null"},
+		{`name(math.abs)`, "math.abs"},
+		{`let outer = fn() { let inner = fn() { 1 }; name(inner) }; outer()`, "inner"},
+		{`map([1, "a", true], type)`, "[INTEGER, STRING, BOOLEAN]"},
+	}
+
+	for _, tt := range tests {
+		result := runVM(t, tt.input)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`int("4x")`, `could not convert "4x" to INTEGER`},
+		{`int([])`, "argument to `int` not supported. got=ARRAY"},
+		{`arity(1)`, "argument to `arity` must be a FUNCTION. got=INTEGER"},
+		{`name("f")`, "argument to `name` must be a FUNCTION. got=STRING"},
+	}
+
+	for _, tt := range errors {
+		result, ok := (runVM(t, tt.input)).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %s", tt.input)
+			continue
+		}
+
+		if result.Message != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%q", tt.input, tt.expected, result.Message)
+		}
+	}
+}
+
+// runVM compiles and runs the input, returning the last popped stack element or
+// the runtime error as an *object.Error
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		return &object.Error{Message: err.(*RuntimeError).Message}
+	}
+
+	return vm.LastPoppedStackElem()
+}
+
+func TestVerify(t *testing.T) {
+	concat := func(instructions ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, ins := range instructions {
+			out = append(out, ins...)
+		}
+		return out
+	}
+
+	identity := &object.CompiledFunction{
+		Instructions:  concat(code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue)),
+		NumLocals:     1,
+		NumParameters: 1,
+	}
+
+	tests := []struct {
+		bytecode *compiler.Bytecode
+		expected string
+	}{
+		{
+			&compiler.Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpConstant, 1), code.Make(code.OpCall, 1), code.Make(code.OpPop)),
+				Constants:    []object.Object{identity, &object.Integer{Value: 1}},
+			},
+			"",
+		},
+		{
+			&compiler.Bytecode{
+				Instructions: concat(code.Make(code.OpConstant, 0), code.Make(code.OpConstant, 0), code.Make(code.OpPop)),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"",
+		},
+		{
+			&compiler.Bytecode{
+				Instructions: concat(code.Make(code.OpConstant, 1), code.Make(code.OpPop)),
+				Constants:    []object.Object{identity},
+			},
+			"invalid bytecode in main program: 0000: constant index 1 out of range, 1 constants",
+		},
+		{
+			&compiler.Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0, 1), code.Make(code.OpPop)),
+				Constants:    []object.Object{identity},
+			},
+			"invalid bytecode in main program: 0000: closure over constant 0 captures 1 free variables, want 0",
+		},
+		{
+			&compiler.Bytecode{
+				Constants: []object.Object{&object.CompiledFunction{Instructions: concat(code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)), NumLocals: 1}},
+			},
+			"invalid bytecode in function at constant 0: 0000: local index 1 out of range, 1 locals",
+		},
+		{
+			&compiler.Bytecode{
+				Constants: []object.Object{&object.CompiledFunction{Instructions: code.Make(code.OpReturn), NumParameters: 2}},
+			},
+			"invalid bytecode in function at constant 0: 2 parameters exceed 0 locals",
+		},
+	}
+
+	for i, tt := range tests {
+		err := Verify(tt.bytecode, object.NewRegistry())
+
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("tests[%d] unexpected error: %s", i, err)
+		case tt.expected != "" && err == nil:
+			t.Errorf("tests[%d] expected error %q", i, tt.expected)
+		case tt.expected != "" && err.Error() != tt.expected:
+			t.Errorf("tests[%d] wrong error. want=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	input := `let double = fn(x) { x * 2 }; map([1, 2], double)`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	events := []string{}
+	instructions := 0
+	vm := New(comp.Bytecode())
+	vm.SetHooks(&Hooks{
+		BeforeInstruction: func(vm *VM) error {
+			instructions++
+			return nil
+		},
+		OnCall: func(vm *VM) {
+			frames := vm.Frames()
+			local := vm.Locals(0)[0]
+			events = append(events, fmt.Sprintf("call %s depth=%d %s=%s", frames[0].Name, vm.CallDepth(), local.Name, local.Value.Inspect()))
+		},
+		OnReturn: func(vm *VM, value object.Object) {
+			events = append(events, "return "+value.Inspect())
+		},
+	})
+
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	expected := "call double depth=2 x=1, return 2, call double depth=2 x=2, return 4"
+	if strings.Join(events, ", ") != expected {
+		t.Errorf("wrong events. want=%q, got=%q", expected, strings.Join(events, ", "))
+	}
+
+	if instructions == 0 {
+		t.Errorf("BeforeInstruction was not called")
+	}
+
+	if names := fmt.Sprint(vm.Globals()[0].Name); names != "double" {
+		t.Errorf("wrong global name. got=%s", names)
+	}
+
+	// An error from a hook stops the vm at the current instruction
+	vm = New(comp.Bytecode())
+	vm.SetHooks(&Hooks{BeforeInstruction: func(vm *VM) error {
+		if vm.CallDepth() > 1 {
+			return fmt.Errorf("stopped")
+		}
+		return nil
+	}})
+
+	err := vm.Run()
+	if err == nil || err.Error() != "1:22: stopped" {
+		t.Errorf("wrong error. want=%q, got=%v", "1:22: stopped", err)
+	}
+}
+
+func TestOutputRedirection(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{`puts("a", 1, [true])`, "a\n1\n[true]\n", ""},
+		{`print("a", 1); print("b")`, "a1b", ""},
+		{`eprint("oops"); puts("done")`, "done\n", "oops\n"},
+		{`map([1, 2], fn(x) { print(x) })`, "12", ""},
+		{`let f = fn() { eprint(json_stringify([1])) }; f()`, "", "[1]\n"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var stdout, stderr strings.Builder
+		vm := New(comp.Bytecode())
+		vm.SetOutput(&stdout, &stderr)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if stdout.String() != tt.stdout {
+			t.Errorf("wrong stdout for %s. want=%q, got=%q", tt.input, tt.stdout, stdout.String())
+		}
+
+		if stderr.String() != tt.stderr {
+			t.Errorf("wrong stderr for %s. want=%q, got=%q", tt.input, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestHostBuiltins(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Namespace("host").Register("double", 1, func(ctx object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	tests := []vmTestCase{
+		{`host.double(21)`, 42},
+		{`let f = fn(x) { host.double(x) }; f(4)`, 8},
+		{`len([host.double(1)])`, 1},
+		{`host.double(1, 2)`, &object.Error{Message: "wrong number of arguments. got=2, want=1"}},
+	}
+
+	for i, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.NewWithBuiltins(builtins)
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error %s", err)
+		}
+
+		vm := NewWithBuiltins(comp.Bytecode(), builtins)
+		err = vm.Run()
+		if expected, ok := tt.expected.(*object.Error); ok {
+			testRuntimeError(t, i, expected, err)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, i, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestBuiltinsCallingClosures(t *testing.T) {
+	builtins := object.NewRegistry()
+	builtins.Register("each", 2, func(ctx object.CallContext, args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		result := make([]object.Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			result[i] = ctx.Call(args[1], el)
+		}
+		return &object.Array{Elements: result}
+	})
+	builtins.RegisterFunc("twice", func(f func(int) (int, error), x int) (int, error) {
+		once, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		return f(once)
+	})
+
+	tests := []vmTestCase{
+		{`each([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`let offset = 10; each([1, 2], fn(x) { x + offset })`, []int{11, 12}},
+		{`let f = fn() { each([1], fn(x) { let y = x + 1; y }) }; f()`, []int{2}},
+		{`each([[1], [2, 3]], len)`, []int{1, 2}},
+		{`each([1, 2], fn(x) { each([x], fn(y) { y * 10 })[0] })`, []int{10, 20}},
+		{`twice(fn(x) { x * 3 }, 2)`, 18},
+	}
+
+	for i, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.NewWithBuiltins(builtins)
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error %s", err)
+		}
+
+		vm := NewWithBuiltins(comp.Bytecode(), builtins)
+		err = vm.Run()
+		if expected, ok := tt.expected.(*object.Error); ok {
+			testRuntimeError(t, i, expected, err)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, i, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestCallbackErrorsStopTheRun(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"map([0], fn(x) { 1 / x });\nputs(\"still running\")", "division by zero", 1, 20},
+		{"let f = fn(x) { x.y };\nfilter([1], f);\nputs(\"still running\")", "field access not supported: INTEGER", 1, 18},
+		{"reduce([1, 2], fn(acc, x) { acc / 0 }); 1", "division by zero", 1, 33},
+		{"reduce([1, 2], fn(x) { x }); 1", "wrong number of arguments: want=1, got=2", 1, 7},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var stdout strings.Builder
+		vm := New(comp.Bytecode())
+		vm.SetOutput(&stdout, &stdout)
+		err := vm.Run()
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("vm did not stop for %q. got=%v", tt.input, err)
+		}
+
+		if rerr.Message != tt.message || rerr.Pos.Line != tt.line || rerr.Pos.Column != tt.column {
+			t.Errorf("wrong vm error for %q. want=%d:%d: %s, got=%s", tt.input, tt.line, tt.column, tt.message, rerr)
+		}
+
+		env := object.NewEnvironment()
+		env.SetOutput(&stdout, &stdout)
+		evaluated, ok := evaluator.Eval(parse(tt.input), env).(*object.Error)
+		if !ok {
+			t.Fatalf("evaluator did not stop for %q. got=%v", tt.input, evaluated)
+		}
+
+		if evaluated.Message != rerr.Message {
+			t.Errorf("engines disagree for %q. evaluator=%q, vm=%q", tt.input, evaluated.Message, rerr.Message)
+		}
+
+		if stdout.Len() != 0 {
+			t.Errorf("program kept running after the error in %q. output=%q", tt.input, stdout.String())
+		}
+	}
+}
+
+func TestBuiltinErrorsStopTheRun(t *testing.T) {
+	tests := []string{
+		`let x = int("x"); puts("after")`,
+		`let f = fn(s) { len(s) }; f(1); puts("after")`,
+		`reduce([1, 2], 5); puts("after")`,
+		`reduce([], fn(acc, x) { acc }); puts("after")`,
+		`reduce(1, fn(acc, x) { acc })`,
+		`map([1], "f")`,
+		`map([[1], 2], len)`,
+		`sort_by([1, 2], fn(x) { [x] })`,
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var stdout strings.Builder
+		vm := New(comp.Bytecode())
+		vm.SetOutput(&stdout, &stdout)
+		err := vm.Run()
+		if _, ok := err.(*RuntimeError); !ok {
+			t.Fatalf("vm did not stop for %q. got=%v", input, err)
+		}
+
+		if stdout.Len() != 0 {
+			t.Errorf("program kept running after the error in %q. output=%q", input, stdout.String())
+		}
+
+		// The evaluator stops with the same error at the same position
+		evaluated := evaluator.Eval(parse(input), object.NewEnvironment())
+		if evaluated.Inspect() != "Error: "+err.Error() {
+			t.Errorf("engines disagree for %q. evaluator=%q, vm=%q", input, evaluated.Inspect(), err)
+		}
+	}
+}
+
+func TestPrintingFunctionsMatchesEvaluator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`puts(fn(x) { x + 1 })`, "fn(x) {\n(x + 1)}\n"},
+		{`let add = fn(a, b) { a + b }; puts(str(add), inspect([add]))`, "fn(a, b) {\n(a + b)}\n[fn(a, b) {\n(a + b)}]\n"},
+		{`let make = fn(n) { fn() { n } }; puts({"f": make(1)})`, "{f: fn() {\nn}}\n"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var vmOut strings.Builder
+		vm := New(comp.Bytecode())
+		vm.SetOutput(&vmOut, nil)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		var evalOut strings.Builder
+		env := object.NewEnvironment()
+		env.SetOutput(&evalOut, nil)
+		evaluator.Eval(parse(tt.input), env)
+
+		if vmOut.String() != tt.expected {
+			t.Errorf("wrong vm output for %s. want=%q, got=%q", tt.input, tt.expected, vmOut.String())
+		}
+
+		if evalOut.String() != vmOut.String() {
+			t.Errorf("engines disagree for %s. evaluator=%q, vm=%q", tt.input, evalOut.String(), vmOut.String())
+		}
+	}
+}
+
+func TestCallingClosuresFromHost(t *testing.T) {
+	program := parse(`let base = 40; fn(x) { base + x }`)
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	fn := vm.LastPoppedStackElem()
+	testExpectedObject(t, 0, 42, vm.Call(fn, &object.Integer{Value: 2}))
+	testExpectedObject(t, 1, 50, vm.Call(fn, &object.Integer{Value: 10}))
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -604,8 +1466,17 @@ func runVMTests(t *testing.T, tests []vmTestCase) {
 			t.Fatalf("compiler error %s", err)
 		}
 
+		// Everything the compiler emits must pass verification
+		if err := Verify(comp.Bytecode(), object.NewRegistry()); err != nil {
+			t.Fatalf("verify error for %s: %s", tt.input, err)
+		}
+
 		vm := New(comp.Bytecode())
 		err = vm.Run()
+		if expected, ok := tt.expected.(*object.Error); ok {
+			testRuntimeError(t, i, expected, err)
+			continue
+		}
 		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
@@ -615,6 +1486,21 @@ func runVMTests(t *testing.T, tests []vmTestCase) {
 	}
 }
 
+// testRuntimeError checks that a builtin failing with the expected error stopped the run
+func testRuntimeError(t *testing.T, testIndex int, expected *object.Error, err error) {
+	t.Helper()
+
+	rerr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Errorf("[index - %d] expected the run to stop with %q. got=%v", testIndex, expected.Message, err)
+		return
+	}
+
+	if rerr.Message != expected.Message {
+		t.Errorf("[index - %d] wrong error message. \nexpected=%q, \ngot=     %q", testIndex, expected.Message, rerr.Message)
+	}
+}
+
 func testExpectedObject(t *testing.T, testIndex int, expected interface{}, actual object.Object) {
 	t.Helper()
 
@@ -625,6 +1511,14 @@ func testExpectedObject(t *testing.T, testIndex int, expected interface{}, actua
 			t.Errorf("[index - %d] testIntegerObject failed: %s", testIndex, err)
 		}
 
+	case float64:
+		f, ok := actual.(*object.Float)
+		if !ok {
+			t.Errorf("[index - %d] object is not Float. got=%T (%+v)", testIndex, actual, actual)
+		} else if f.Value != expected {
+			t.Errorf("[index - %d] object has wrong value. got=%v, want=%v", testIndex, f.Value, expected)
+		}
+
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
@@ -656,6 +1550,25 @@ func testExpectedObject(t *testing.T, testIndex int, expected interface{}, actua
 			}
 		}
 
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array, %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testStringObject(expectedElem, array.Elements[i])
+			if err != nil {
+				t.Errorf("testStringObject failed: %s", err)
+			}
+		}
+
 	case map[object.HashKey]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
@@ -663,14 +1576,16 @@ func testExpectedObject(t *testing.T, testIndex int, expected interface{}, actua
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
-			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d", len(expected), len(hash.Pairs))
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d", len(expected), hash.Len())
 		}
 
-		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+		for _, pair := range hash.Pairs() {
+			key, _ := object.HashKeyOf(pair.Key)
+			expectedValue, ok := expected[key]
 			if !ok {
-				t.Errorf("no pair for given key in Pairs")
+				t.Errorf("unexpected key in Pairs: %s", pair.Key.Inspect())
+				continue
 			}
 
 			err := testIntegerObject(expectedValue, pair.Value)